@@ -0,0 +1,244 @@
+package lineatur
+
+import "strings"
+
+// stroke holds the attributes lines are stroked with.
+type stroke struct {
+	width     float64
+	lineCap   string
+	dashArray []float64
+	dashPhase float64
+	r, g, b   int
+	alpha     float64
+	blendMode string
+}
+
+func (s stroke) same(o stroke) bool {
+	return s.width == o.width && s.lineCap == o.lineCap && s.sameDash(o) && s.r == o.r && s.g == o.g && s.b == o.b && s.alpha == o.alpha && s.blendMode == o.blendMode
+}
+
+func (s stroke) sameDash(o stroke) bool {
+	if s.dashPhase != o.dashPhase || len(s.dashArray) != len(o.dashArray) {
+		return false
+	}
+	for i := range s.dashArray {
+		if s.dashArray[i] != o.dashArray[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// batched collects the lines drawn one after another with the same stroke
+// attributes and strokes them as one path, so dense rulings and grids don't
+// repeat the stroke operator and the attributes for every line. Drawing
+// anything else strokes the collected lines first, the order of the drawing
+// stays the same.
+type batched struct {
+	Canvas
+	stroke  stroke         // the attributes set last
+	applied *stroke        // the attributes of the canvas, nil if unknown
+	alpha   bool           // the canvas got an opacity, it isn't opaque by default
+	lines   [][][2]float64 // the collected lines as lists of points
+	linesOf stroke         // the attributes of the collected lines
+	path    [][][2]float64 // the path of MoveTo and LineTo
+}
+
+// newBatched returns the batching canvas drawing on pdf, with the default
+// attributes of gofpdf.
+func newBatched(pdf Canvas) *batched {
+	return &batched{Canvas: pdf, stroke: stroke{width: 0.2, lineCap: "butt", alpha: 1, blendMode: "Normal"}}
+}
+
+// add collects the lines, those collected before with other attributes are
+// stroked first.
+func (b *batched) add(lines ...[][2]float64) {
+	if len(b.lines) > 0 && !b.linesOf.same(b.stroke) {
+		b.flush()
+	}
+	if len(b.lines) == 0 {
+		b.linesOf = b.stroke
+	}
+	b.lines = append(b.lines, lines...)
+}
+
+// flush strokes the collected lines as one path.
+func (b *batched) flush() {
+	if len(b.lines) == 0 {
+		return
+	}
+	b.apply(b.linesOf)
+	for _, points := range b.lines {
+		b.Canvas.MoveTo(points[0][0], points[0][1])
+		for _, p := range points[1:] {
+			b.Canvas.LineTo(p[0], p[1])
+		}
+	}
+	b.Canvas.DrawPath("D")
+	b.lines = nil
+}
+
+// apply sets the attributes of the canvas that differ.
+func (b *batched) apply(s stroke) {
+	a := b.applied
+	if a == nil || a.width != s.width {
+		b.Canvas.SetLineWidth(s.width)
+	}
+	if a == nil || a.lineCap != s.lineCap {
+		b.Canvas.SetLineCapStyle(s.lineCap)
+	}
+	if a == nil || !a.sameDash(s) {
+		b.Canvas.SetDashPattern(s.dashArray, s.dashPhase)
+	}
+	if a == nil || a.r != s.r || a.g != s.g || a.b != s.b {
+		b.Canvas.SetDrawColor(s.r, s.g, s.b)
+	}
+	// a PDF gets a graphics state for every opacity, an opaque drawing
+	// needs none
+	opaque := s.alpha == 1 && s.blendMode == "Normal"
+	if (a == nil && (b.alpha || !opaque)) || (a != nil && (a.alpha != s.alpha || a.blendMode != s.blendMode)) {
+		b.Canvas.SetAlpha(s.alpha, s.blendMode)
+		b.alpha = true
+	}
+	b.applied = &s
+}
+
+// paint strokes the collected lines and sets the current attributes before
+// drawing anything else.
+func (b *batched) paint() {
+	b.flush()
+	b.apply(b.stroke)
+}
+
+// forget strokes the collected lines before the canvas restores or resets its
+// attributes, they are set again before drawing.
+func (b *batched) forget(restore func()) {
+	b.flush()
+	restore()
+	b.applied = nil
+}
+
+func (b *batched) AddPage() {
+	b.forget(b.Canvas.AddPage)
+}
+
+// SetFooterFunc strokes the lines of the footer before the page is finished.
+func (b *batched) SetFooterFunc(fnc func()) {
+	b.Canvas.SetFooterFunc(func() {
+		fnc()
+		b.flush()
+	})
+}
+
+func (b *batched) SetLineWidth(width float64) {
+	b.stroke.width = width
+}
+
+func (b *batched) SetLineCapStyle(styleStr string) {
+	b.stroke.lineCap = styleStr
+}
+
+func (b *batched) SetDashPattern(dashArray []float64, dashPhase float64) {
+	b.stroke.dashArray = append([]float64(nil), dashArray...)
+	b.stroke.dashPhase = dashPhase
+}
+
+func (b *batched) SetDrawColor(r, g, bl int) {
+	b.stroke.r, b.stroke.g, b.stroke.b = r, g, bl
+}
+
+func (b *batched) SetAlpha(alpha float64, blendModeStr string) {
+	b.stroke.alpha, b.stroke.blendMode = alpha, blendModeStr
+}
+
+func (b *batched) MoveTo(x, y float64) {
+	b.path = append(b.path, [][2]float64{{x, y}})
+}
+
+func (b *batched) LineTo(x, y float64) {
+	if len(b.path) == 0 {
+		b.MoveTo(x, y)
+		return
+	}
+	b.path[len(b.path)-1] = append(b.path[len(b.path)-1], [2]float64{x, y})
+}
+
+// DrawPath collects a stroked path, a filled one is drawn right away.
+func (b *batched) DrawPath(styleStr string) {
+	path := b.path
+	b.path = nil
+	if strings.ToUpper(styleStr) == "D" {
+		b.add(path...)
+		return
+	}
+	b.paint()
+	for _, points := range path {
+		b.Canvas.MoveTo(points[0][0], points[0][1])
+		for _, p := range points[1:] {
+			b.Canvas.LineTo(p[0], p[1])
+		}
+	}
+	b.Canvas.DrawPath(styleStr)
+}
+
+func (b *batched) Line(x1, y1, x2, y2 float64) {
+	b.add([][2]float64{{x1, y1}, {x2, y2}})
+}
+
+func (b *batched) Rect(x, y, w, h float64, styleStr string) {
+	b.paint()
+	b.Canvas.Rect(x, y, w, h, styleStr)
+}
+
+func (b *batched) Circle(x, y, r float64, styleStr string) {
+	b.paint()
+	b.Canvas.Circle(x, y, r, styleStr)
+}
+
+func (b *batched) Ellipse(x, y, rx, ry, degRotate float64, styleStr string) {
+	b.paint()
+	b.Canvas.Ellipse(x, y, rx, ry, degRotate, styleStr)
+}
+
+func (b *batched) Text(x, y float64, txtStr string) {
+	b.paint()
+	b.Canvas.Text(x, y, txtStr)
+}
+
+func (b *batched) Image(imageNameStr string, x, y, w, h float64, flow bool, tp string, link int, linkStr string) {
+	b.paint()
+	b.Canvas.Image(imageNameStr, x, y, w, h, flow, tp, link, linkStr)
+}
+
+func (b *batched) TransformBegin() {
+	b.flush()
+	b.Canvas.TransformBegin()
+}
+
+func (b *batched) TransformTranslate(tx, ty float64) {
+	b.flush()
+	b.Canvas.TransformTranslate(tx, ty)
+}
+
+func (b *batched) TransformRotate(angle, x, y float64) {
+	b.flush()
+	b.Canvas.TransformRotate(angle, x, y)
+}
+
+func (b *batched) TransformScale(scaleWd, scaleHt, x, y float64) {
+	b.flush()
+	b.Canvas.TransformScale(scaleWd, scaleHt, x, y)
+}
+
+func (b *batched) TransformEnd() {
+	b.forget(b.Canvas.TransformEnd)
+}
+
+func (b *batched) ClipRect(x, y, w, h float64, outline bool) {
+	b.paint()
+	b.Canvas.ClipRect(x, y, w, h, outline)
+}
+
+func (b *batched) ClipEnd() {
+	b.forget(b.Canvas.ClipEnd)
+}
@@ -0,0 +1,184 @@
+package lineatur
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// recorder records the drawing operators, the others aren't used.
+type recorder struct {
+	Canvas
+	calls []string
+}
+
+func (r *recorder) record(format string, a ...interface{}) {
+	r.calls = append(r.calls, fmt.Sprintf(format, a...))
+}
+
+func (r *recorder) SetLineWidth(width float64) { r.record("width %g", width) }
+
+func (r *recorder) SetLineCapStyle(styleStr string) { r.record("cap %s", styleStr) }
+
+func (r *recorder) SetDashPattern(dashArray []float64, dashPhase float64) {
+	r.record("dash %v %g", dashArray, dashPhase)
+}
+
+func (r *recorder) SetDrawColor(red, green, blue int) { r.record("color %d %d %d", red, green, blue) }
+
+func (r *recorder) SetAlpha(alpha float64, blendModeStr string) {
+	r.record("alpha %g %s", alpha, blendModeStr)
+}
+
+func (r *recorder) MoveTo(x, y float64) { r.record("move %g %g", x, y) }
+
+func (r *recorder) LineTo(x, y float64) { r.record("line %g %g", x, y) }
+
+func (r *recorder) DrawPath(styleStr string) { r.record("path %s", styleStr) }
+
+func (r *recorder) Rect(x, y, w, h float64, styleStr string) {
+	r.record("rect %g %g %g %g %s", x, y, w, h, styleStr)
+}
+
+func TestBatchedFlushes(t *testing.T) {
+	defaults := []string{"width 0.2", "cap butt", "dash [] 0", "color 0 0 0"}
+	tests := []struct {
+		name string
+		draw func(c Canvas)
+		want []string
+	}{{
+		name: "same attributes",
+		draw: func(c Canvas) {
+			c.Line(0, 0, 1, 0)
+			c.Line(0, 1, 1, 1)
+		},
+		want: append(defaults, "move 0 0", "line 1 0", "move 0 1", "line 1 1", "path D"),
+	}, {
+		name: "colour",
+		draw: func(c Canvas) {
+			c.Line(0, 0, 1, 0)
+			c.SetDrawColor(255, 0, 0)
+			c.Line(0, 1, 1, 1)
+		},
+		want: append(defaults, "move 0 0", "line 1 0", "path D", "color 255 0 0", "move 0 1", "line 1 1", "path D"),
+	}, {
+		name: "width",
+		draw: func(c Canvas) {
+			c.Line(0, 0, 1, 0)
+			c.SetLineWidth(0.5)
+			c.Line(0, 1, 1, 1)
+		},
+		want: append(defaults, "move 0 0", "line 1 0", "path D", "width 0.5", "move 0 1", "line 1 1", "path D"),
+	}, {
+		name: "dash",
+		draw: func(c Canvas) {
+			c.Line(0, 0, 1, 0)
+			c.SetDashPattern([]float64{1, 2}, 0)
+			c.Line(0, 1, 1, 1)
+			c.SetDashPattern([]float64{1, 2}, 1)
+			c.Line(0, 2, 1, 2)
+		},
+		want: append(defaults, "move 0 0", "line 1 0", "path D", "dash [1 2] 0", "move 0 1", "line 1 1", "path D",
+			"dash [1 2] 1", "move 0 2", "line 1 2", "path D"),
+	}, {
+		name: "colour set back",
+		draw: func(c Canvas) {
+			c.Line(0, 0, 1, 0)
+			c.SetDrawColor(255, 0, 0)
+			c.SetDrawColor(0, 0, 0)
+			c.Line(0, 1, 1, 1)
+		},
+		want: append(defaults, "move 0 0", "line 1 0", "move 0 1", "line 1 1", "path D"),
+	}, {
+		name: "path",
+		draw: func(c Canvas) {
+			c.MoveTo(0, 0)
+			c.LineTo(1, 0)
+			c.LineTo(1, 1)
+			c.DrawPath("D")
+			c.SetLineWidth(0.5)
+			c.Line(0, 1, 1, 1)
+		},
+		want: append(defaults, "move 0 0", "line 1 0", "line 1 1", "path D", "width 0.5", "move 0 1", "line 1 1", "path D"),
+	}, {
+		name: "rectangle",
+		draw: func(c Canvas) {
+			c.Line(0, 0, 1, 0)
+			c.SetDrawColor(255, 0, 0)
+			c.Rect(0, 1, 1, 1, "F")
+			c.Line(0, 2, 1, 2)
+		},
+		want: append(defaults, "move 0 0", "line 1 0", "path D", "color 255 0 0", "rect 0 1 1 1 F", "move 0 2", "line 1 2", "path D"),
+	}, {
+		name: "opacity",
+		draw: func(c Canvas) {
+			c.Line(0, 0, 1, 0)
+			c.SetAlpha(0.5, "Normal")
+			c.Line(0, 1, 1, 1)
+		},
+		want: append(defaults, "move 0 0", "line 1 0", "path D", "alpha 0.5 Normal", "move 0 1", "line 1 1", "path D"),
+	}}
+	for _, test := range tests {
+		r := &recorder{}
+		b := newBatched(r)
+		test.draw(b)
+		b.flush()
+		if !reflect.DeepEqual(r.calls, test.want) {
+			t.Errorf("%s: drew\n%q\nwant\n%q", test.name, r.calls, test.want)
+		}
+	}
+}
+
+func TestBatchedSameAsUnbatched(t *testing.T) {
+	base := Config{
+		PaperSize:     PaperSizes["A5"],
+		Margins:       []float64{10, 10, 10, 10},
+		LineHeight:    12,
+		LineSpacing:   6,
+		LineWidth:     0.2,
+		LineColor:     Color{120, 120, 120},
+		SlantColor:    Color{200, 120, 120},
+		BaselineColor: Color{0, 0, 200},
+		Proportions:   []float64{3, 2, 3},
+		Font:          "Helvetica",
+		TitleSize:     14,
+	}
+	slanted := base
+	slanted.Slants = []float64{55, 10}
+	slanted.ZoneStyles = []string{"solid", "dashed", "dotted"}
+	slanted.LineColors = []Color{{0, 0, 0}, {255, 0, 0}}
+	slanted.Baseline = true
+	slanted.MarginLine = 20
+	slanted.MarginLineColor = Color{255, 0, 0}
+	squares := base
+	squares.Grid = "squares"
+	squares.GridSpacing = 5
+	squares.GridMajor = 4
+	squares.MajorWidth = 0.4
+	squares.MajorColor = Color{0, 0, 255}
+	dots := base
+	dots.Grid = "dots"
+	dots.GridSpacing = 5
+	for name, cfg := range map[string]Config{"lines": base, "slants": slanted, "squares": squares, "dots": dots} {
+		if err := cfg.Validate(); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		unbatched := NewPNG(cfg.media(), 72)
+		DrawPages(unbatched, cfg)
+		batched := NewPNG(cfg.media(), 72)
+		b := newBatched(batched)
+		DrawPages(b, cfg)
+		b.flush()
+		var want, got bytes.Buffer
+		if err := unbatched.Output(&want); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if err := batched.Output(&got); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !bytes.Equal(got.Bytes(), want.Bytes()) {
+			t.Errorf("%s: the batched PNG differs from the unbatched one", name)
+		}
+	}
+}
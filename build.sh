@@ -1,7 +1,9 @@
 #!/bin/sh
 
-GOOS=windows GOARCH=amd64 go build -o lineatur-win-amd64
-GOOS=darwin GOARCH=amd64 go build -o lineatur-mac-amd64
-GOOS=darwin GOARCH=arm64 go build -o lineatur-mac-arm64
-GOOS=linux GOARCH=amd64 go build -o lineatur-linux-amd64
-GOOS=linux GOARCH=arm64 go build -o lineatur-linux-arm64
+LDFLAGS="-X github.com/maptry/lineatur.Version=$(git describe --tags --always --dirty)"
+
+GOOS=windows GOARCH=amd64 go build -ldflags "$LDFLAGS" -o lineatur-win-amd64 ./cmd/lineatur
+GOOS=darwin GOARCH=amd64 go build -ldflags "$LDFLAGS" -o lineatur-mac-amd64 ./cmd/lineatur
+GOOS=darwin GOARCH=arm64 go build -ldflags "$LDFLAGS" -o lineatur-mac-arm64 ./cmd/lineatur
+GOOS=linux GOARCH=amd64 go build -ldflags "$LDFLAGS" -o lineatur-linux-amd64 ./cmd/lineatur
+GOOS=linux GOARCH=arm64 go build -ldflags "$LDFLAGS" -o lineatur-linux-arm64 ./cmd/lineatur
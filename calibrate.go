@@ -0,0 +1,80 @@
+package lineatur
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// calibrationSize is the side of the reference square of the calibration
+// page in mm.
+const calibrationSize = 100.0
+
+// drawRuler draws mm ticks from x, y along the length, across the page if
+// vertical. Every 5 mm the tick is longer and every 10 mm it is numbered in
+// cm.
+func drawRuler(pdf Canvas, x, y, length float64, vertical bool) {
+	pdf.SetLineWidth(0.1)
+	pdf.SetFont("Helvetica", "", 6)
+	_, fontHeight := pdf.GetFontSize()
+	for mm := 0; float64(mm) <= length; mm++ {
+		tick := 1.5
+		switch {
+		case mm%10 == 0:
+			tick = 4
+		case mm%5 == 0:
+			tick = 2.5
+		}
+		label := strconv.Itoa(mm / 10)
+		if vertical {
+			pdf.Line(x, y+float64(mm), x+tick, y+float64(mm))
+			if mm%10 == 0 && mm > 0 {
+				pdf.Text(x+tick+0.5, y+float64(mm)+fontHeight/3, label)
+			}
+		} else {
+			pdf.Line(x+float64(mm), y, x+float64(mm), y+tick)
+			if mm%10 == 0 && mm > 0 {
+				pdf.Text(x+float64(mm)-pdf.GetStringWidth(label)/2, y+tick+fontHeight, label)
+			}
+		}
+	}
+}
+
+// drawCalibration draws the page to measure the printer with: a square of
+// 100 mm in the center, mm rulers along the top and left margins and the
+// instructions below the square.
+func drawCalibration(pdf Canvas, cfg Config) {
+	size := cfg.canvas()
+	top, left := cfg.Margins[0], cfg.Margins[3]
+	pdf.SetDrawColor(0, 0, 0)
+	drawRuler(pdf, left, top, size.Width-cfg.Margins[1]-left, false)
+	drawRuler(pdf, left, top, size.Height-cfg.Margins[2]-top, true)
+	x, y := (size.Width-calibrationSize)/2, (size.Height-calibrationSize)/2
+	pdf.SetLineWidth(0.2)
+	pdf.Rect(x, y, calibrationSize, calibrationSize, "D")
+	// the center cross helps to measure across the square
+	pdf.SetDashPattern([]float64{1, 1}, 0)
+	pdf.Line(x, y+calibrationSize/2, x+calibrationSize, y+calibrationSize/2)
+	pdf.Line(x+calibrationSize/2, y, x+calibrationSize/2, y+calibrationSize)
+	pdf.SetDashPattern([]float64{}, 0)
+	pdf.SetFont("Helvetica", "", 9)
+	label := fmt.Sprintf("%g mm", calibrationSize)
+	pdf.Text(x+(calibrationSize-pdf.GetStringWidth(label))/2, y-1.5, label)
+	pdf.TransformBegin()
+	pdf.TransformRotate(90, x-1.5, y+calibrationSize/2)
+	pdf.Text(x-1.5-pdf.GetStringWidth(label)/2, y+calibrationSize/2, label)
+	pdf.TransformEnd()
+	scale := "Without -scale the lines are printed as they are"
+	if len(cfg.Scale) == 2 {
+		scale = fmt.Sprintf("This page is printed with -scale %.4g:%.4g", cfg.Scale[0], cfg.Scale[1])
+	}
+	_, lineHeight := pdf.GetFontSize()
+	for i, line := range []string{
+		"Print this page at 100 % or actual size, not fit to page.",
+		fmt.Sprintf("Both sides of the square must measure %g mm, the rulers count cm from the margins.", calibrationSize),
+		fmt.Sprintf("If the square measures w mm wide and h mm high, print with -scale %g/w:%g/h,", calibrationSize, calibrationSize),
+		fmt.Sprintf("e.g. -scale %g/98 if both sides measure 98 mm.", calibrationSize),
+		scale + ".",
+	} {
+		pdf.Text(x, y+calibrationSize+8+float64(i)*1.5*lineHeight, line)
+	}
+}
@@ -0,0 +1,176 @@
+package lineatur
+
+import (
+	"bytes"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"math"
+	"os"
+)
+
+// Canvas is the part of gofpdf.Fpdf the pages are drawn with. Besides
+// *gofpdf.Fpdf it is implemented by the SVG, DXF and PNG writers.
+type Canvas interface {
+	AddPage()
+	SetFooterFunc(fnc func())
+	SetLineWidth(width float64)
+	SetLineCapStyle(styleStr string)
+	SetDashPattern(dashArray []float64, dashPhase float64)
+	SetDrawColor(r, g, b int)
+	SetFillColor(r, g, b int)
+	SetAlpha(alpha float64, blendModeStr string)
+	MoveTo(x, y float64)
+	LineTo(x, y float64)
+	DrawPath(styleStr string)
+	Line(x1, y1, x2, y2 float64)
+	Rect(x, y, w, h float64, styleStr string)
+	Circle(x, y, r float64, styleStr string)
+	Ellipse(x, y, rx, ry, degRotate float64, styleStr string)
+	AddUTF8FontFromBytes(familyStr, styleStr string, utf8Bytes []byte)
+	SetFont(familyStr, styleStr string, size float64)
+	GetFontSize() (ptSize, unitSize float64)
+	GetStringWidth(s string) float64
+	SetTextColor(r, g, b int)
+	Text(x, y float64, txtStr string)
+	TransformBegin()
+	TransformTranslate(tx, ty float64)
+	TransformRotate(angle, x, y float64)
+	TransformScale(scaleWd, scaleHt, x, y float64)
+	TransformEnd()
+	ClipRect(x, y, w, h float64, outline bool)
+	ClipEnd()
+	Image(imageNameStr string, x, y, w, h float64, flow bool, tp string, link int, linkStr string)
+}
+
+// affine is a transformation of page coordinates made of translations,
+// rotations and scalings, x' = a*x + c*y + e and y' = b*x + d*y + f.
+type affine [6]float64
+
+// identity is the affine transformation that keeps the coordinates.
+var identity = affine{1, 0, 0, 1, 0, 0}
+
+func (m affine) apply(x, y float64) (float64, float64) {
+	return m[0]*x + m[2]*y + m[4], m[1]*x + m[3]*y + m[5]
+}
+
+// then returns the transformation applying n before m, like a transformation
+// added in a transform of gofpdf.
+func (m affine) then(n affine) affine {
+	return affine{
+		m[0]*n[0] + m[2]*n[1], m[1]*n[0] + m[3]*n[1],
+		m[0]*n[2] + m[2]*n[3], m[1]*n[2] + m[3]*n[3],
+		m[0]*n[4] + m[2]*n[5] + m[4], m[1]*n[4] + m[3]*n[5] + m[5],
+	}
+}
+
+// moves reports whether the transformation only moves the coordinates.
+func (m affine) moves() bool {
+	return m[0] == 1 && m[1] == 0 && m[2] == 0 && m[3] == 1
+}
+
+// scale returns the length of a horizontal mm after the transformation.
+func (m affine) scale() float64 {
+	return math.Hypot(m[0], m[1])
+}
+
+// angle returns the counter-clockwise rotation of the transformation in
+// degrees.
+func (m affine) angle() float64 {
+	// the y axis of the page points down
+	return math.Atan2(-m[1], m[0]) * 180 / math.Pi
+}
+
+// translation returns the affine transformation moving by tx, ty.
+func translation(tx, ty float64) affine {
+	return affine{1, 0, 0, 1, tx, ty}
+}
+
+// scaling returns the affine transformation scaling by the percentages
+// around x, y like in gofpdf.
+func scaling(scaleWd, scaleHt, x, y float64) affine {
+	sx, sy := scaleWd/100, scaleHt/100
+	return affine{sx, 0, 0, sy, x - x*sx, y - y*sy}
+}
+
+// rotation returns the affine transformation turning counter-clockwise by
+// the angle in degrees around x, y like in gofpdf.
+func rotation(angle, x, y float64) affine {
+	sin, cos := math.Sincos(angle * math.Pi / 180)
+	// counter-clockwise on the page, whose y axis points down
+	return affine{cos, -sin, sin, cos, x - x*cos - y*sin, y + x*sin - y*cos}
+}
+
+// transforms is the stack of the transformations per TransformBegin.
+type transforms []affine
+
+// apply transforms the coordinates with the open transformations.
+func (t transforms) apply(x, y float64) (float64, float64) {
+	return t.matrix().apply(x, y)
+}
+
+// matrix returns the open transformations as one.
+func (t transforms) matrix() affine {
+	m := identity
+	for _, n := range t {
+		m = m.then(n)
+	}
+	return m
+}
+
+// add adds the transformation to the innermost transform.
+func (t transforms) add(m affine) {
+	t[len(t)-1] = t[len(t)-1].then(m)
+}
+
+// box returns the bounding box of the transformed rectangle.
+func (t transforms) box(x, y, w, h float64) (float64, float64, float64, float64) {
+	m := t.matrix()
+	x1, y1 := math.Inf(1), math.Inf(1)
+	x2, y2 := math.Inf(-1), math.Inf(-1)
+	for _, corner := range [][2]float64{{x, y}, {x + w, y}, {x + w, y + h}, {x, y + h}} {
+		cx, cy := m.apply(corner[0], corner[1])
+		x1, y1, x2, y2 = math.Min(x1, cx), math.Min(y1, cy), math.Max(x2, cx), math.Max(y2, cy)
+	}
+	return x1, y1, x2 - x1, y2 - y1
+}
+
+// readImage reads a JPEG, PNG or GIF file for Image with its format and
+// size on the page like in gofpdf: a missing width or height keeps the
+// aspect ratio, without both the image has 72 dpi.
+func readImage(filename string, w, h float64) (data []byte, img image.Image, format string, width, height float64, err error) {
+	data, err = os.ReadFile(filename)
+	if err != nil {
+		return nil, nil, "", 0, 0, err
+	}
+	img, format, err = image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, nil, "", 0, 0, err
+	}
+	dx, dy := float64(img.Bounds().Dx()), float64(img.Bounds().Dy())
+	switch {
+	case w == 0 && h == 0:
+		w, h = dx*25.4/72, dy*25.4/72
+	case w == 0:
+		w = h * dx / dy
+	case h == 0:
+		h = w * dy / dx
+	}
+	return data, img, format, w, h, nil
+}
+
+// imageHeight returns the height of the image file at the width, keeping its
+// aspect ratio, 0 if it can't be read.
+func imageHeight(filename string, width float64) float64 {
+	f, err := os.Open(filename)
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+	c, _, err := image.DecodeConfig(f)
+	if err != nil || c.Width == 0 {
+		return 0
+	}
+	return width * float64(c.Height) / float64(c.Width)
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// batchJob is a sheet of a batch file, the arguments of one run.
+type batchJob struct {
+	Line int
+	Args []string
+}
+
+// readBatch reads the jobs of a batch file, one command line per line, e.g.
+// "-preset kurrent -lh 9 -o kurrent.pdf". Empty lines and lines starting
+// with # are skipped. Every job needs its own output file.
+func readBatch(flags *flag.FlagSet, filename string) ([]batchJob, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	jobs := []batchJob{}
+	outputs := map[string]int{}
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		args := strings.Fields(line)
+		values, err := parseArguments(flags, args)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", i+1, err)
+		}
+		output := ""
+		for _, value := range values {
+			switch value[0] {
+			case "o":
+				output = value[1]
+			case "batch", "bench", "list-presets":
+				return nil, fmt.Errorf("line %d: -%s can't be used in a batch", i+1, value[0])
+			}
+		}
+		switch {
+		case output == "" || output == "-":
+			return nil, fmt.Errorf("line %d: every job needs an output file with -o", i+1)
+		case outputs[output] > 0:
+			return nil, fmt.Errorf("line %d: %s is written by line %d too", i+1, output, outputs[output])
+		}
+		outputs[output] = i + 1
+		jobs = append(jobs, batchJob{i + 1, args})
+	}
+	if len(jobs) == 0 {
+		return nil, errors.New("the batch has no jobs")
+	}
+	return jobs, nil
+}
+
+// runBatch runs this program once for every job, workers at a time, with the
+// shared arguments before those of the job, so the job overrides them. The
+// output of the failed jobs is written to w.
+func runBatch(w io.Writer, jobs []batchJob, shared []string, workers int) error {
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	failures := make([]string, len(jobs))
+	queue := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range queue {
+				output, err := exec.Command(executable, append(append([]string{}, shared...), jobs[j].Args...)...).CombinedOutput()
+				if err != nil {
+					failures[j] = strings.TrimSpace(string(output))
+					if failures[j] == "" {
+						failures[j] = err.Error()
+					}
+				}
+			}
+		}()
+	}
+	for j := range jobs {
+		queue <- j
+	}
+	close(queue)
+	wg.Wait()
+	failed := 0
+	for j, failure := range failures {
+		if failure != "" {
+			failed++
+			fmt.Fprintf(w, "line %d: %s\n", jobs[j].Line, failure)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d jobs failed", failed, len(jobs))
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/maptry/lineatur"
+)
+
+// benchRuns is the number of times each layout is rendered, the fastest
+// run is reported.
+const benchRuns = 5
+
+// benchDPI is the resolution of the png format, the -dpi default.
+const benchDPI = 300
+
+// benchLayout is a representative configuration rendered by -bench.
+type benchLayout struct {
+	Name   string
+	Config lineatur.Config
+}
+
+// benchLayouts returns the layouts of -bench, derived from the defaults of
+// the command line.
+func benchLayouts() []benchLayout {
+	base := lineatur.Config{
+		PaperSize:        lineatur.PaperSizes["A4"],
+		Margins:          []float64{5, 15, 15, 5},
+		LineHeight:       10,
+		LineSpacing:      5,
+		LineWidth:        0.3,
+		CenterCrossColor: lineatur.Color{R: 200, G: 200, B: 200},
+	}
+	layouts := []benchLayout{{"single line", base}}
+	add := func(name string, change func(cfg *lineatur.Config)) {
+		cfg := base
+		change(&cfg)
+		layouts = append(layouts, benchLayout{name, cfg})
+	}
+	add("kurrent", func(cfg *lineatur.Config) {
+		cfg.Proportions, cfg.Slants = lineatur.Presets["kurrent"].Proportions, lineatur.Presets["kurrent"].Slants
+	})
+	add("italic tinted", func(cfg *lineatur.Config) {
+		p := lineatur.Presets["italic"]
+		cfg.Proportions, cfg.Slants, cfg.ZoneColors = p.Proportions, p.Slants, p.ZoneColors
+	})
+	add("zone styles", func(cfg *lineatur.Config) {
+		cfg.Proportions = []float64{3, 4, 3}
+		cfg.ZoneStyles = []string{"solid", "dashed", "dotted"}
+		cfg.ZoneDims, cfg.UnitTicks = "all", true
+	})
+	add("fade right", func(cfg *lineatur.Config) {
+		cfg.Proportions, cfg.Slants, cfg.FadeRight = []float64{2, 3, 2}, []float64{75, 10}, 0.6
+	})
+	add("vignette", func(cfg *lineatur.Config) {
+		cfg.Proportions, cfg.Vignette = []float64{1, 1, 1}, 0.3
+	})
+	add("compare", func(cfg *lineatur.Config) {
+		cfg.Compare = []lineatur.LabeledProportions{
+			{Label: "Kurrent", Proportions: []float64{2, 1, 2}},
+			{Label: "Copperplate", Proportions: []float64{3, 2, 3}},
+		}
+	})
+	add("poster A2", func(cfg *lineatur.Config) {
+		cfg.Proportions, cfg.Slants = []float64{2, 1, 2}, []float64{60, 10}
+		cfg.Poster, cfg.PosterOverlap = lineatur.PaperSize{Width: 420, Height: 594}, 10
+	})
+	add("booklet 8", func(cfg *lineatur.Config) {
+		cfg.Proportions, cfg.Booklet = []float64{3, 4, 3}, 8
+	})
+	return layouts
+}
+
+// countingWriter discards everything written to it and counts the bytes.
+type countingWriter struct {
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
+
+// runBench renders every layout in every format and reports the time and
+// the size of the output.
+func runBench(w io.Writer) error {
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(table, "layout\tformat\ttime\tbytes\t")
+	var total time.Duration
+	for _, layout := range benchLayouts() {
+		if err := layout.Config.Validate(); err != nil {
+			return fmt.Errorf("layout %s: %s", layout.Name, err)
+		}
+		for _, format := range []string{"pdf", "svg", "dxf", "png"} {
+			var best time.Duration
+			var size int64
+			for i := 0; i < benchRuns; i++ {
+				counter := &countingWriter{}
+				start := time.Now()
+				if err := lineatur.Render(layout.Config, format, "mm", "LINEATUR", benchDPI)(counter); err != nil {
+					return fmt.Errorf("layout %s as %s: %s", layout.Name, format, err)
+				}
+				if elapsed := time.Since(start); i == 0 || elapsed < best {
+					best = elapsed
+				}
+				size = counter.n
+			}
+			total += best
+			fmt.Fprintf(table, "%s\t%s\t%s\t%d\t\n", layout.Name, format, best.Round(time.Microsecond), size)
+		}
+	}
+	fmt.Fprintf(table, "total\t\t%s\t\t\n", total.Round(time.Microsecond))
+	return table.Flush()
+}
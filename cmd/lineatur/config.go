@@ -0,0 +1,203 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/maptry/lineatur"
+)
+
+// applyConfigFile sets the flags from a JSON object of flag names and
+// values, e.g. {"p": "3:2:3", "s": "55:10", "lw": 0.2}. Flags given on the
+// command line win over the file.
+func applyConfigFile(flags *flag.FlagSet, filename string) error {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	values := map[string]interface{}{}
+	if err := json.Unmarshal(data, &values); err != nil {
+		return err
+	}
+	given := map[string]bool{}
+	flags.Visit(func(f *flag.Flag) { given[f.Name] = true })
+	// sorted for the same errors on every run
+	names := []string{}
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if name == "config" || flags.Lookup(name) == nil {
+			return fmt.Errorf("unknown flag %s", name)
+		}
+		if given[name] {
+			continue
+		}
+		switch value := values[name].(type) {
+		case string, float64, bool:
+			if err := flags.Set(name, fmt.Sprint(value)); err != nil {
+				return fmt.Errorf("wrong value for %s: %s", name, err)
+			}
+		default:
+			return fmt.Errorf("value of %s must be a string, number or boolean", name)
+		}
+	}
+	return nil
+}
+
+// userPresets are the presets of the user, read before the flags are parsed
+// for the usage.
+var userPresets = map[string][]string{}
+
+// userPresetNames returns the names of the user presets, sorted.
+func userPresetNames() []string {
+	names := []string{}
+	for name := range userPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// userPresetsFile is the file of the user presets in the user's config
+// directory, ~/.config/lineatur/presets on Linux.
+func userPresetsFile() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "lineatur", "presets"), nil
+}
+
+// readUserPresets reads the user presets, one per line as a name and the
+// arguments it stands for, e.g. "my-copperplate = -p 3:2:3 -s 55:12 -lh 8".
+// Empty lines and lines starting with # are skipped, a missing file has no
+// presets.
+func readUserPresets(filename string) (map[string][]string, error) {
+	data, err := os.ReadFile(filename)
+	if errors.Is(err, fs.ErrNotExist) {
+		return map[string][]string{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	presets := map[string][]string{}
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, args, ok := strings.Cut(line, "=")
+		name = strings.TrimSpace(name)
+		_, builtIn := lineatur.Presets[name]
+		_, twice := presets[name]
+		switch {
+		case !ok || name == "" || strings.ContainsAny(name, " \t"):
+			return nil, fmt.Errorf("line %d: expected name = arguments", i+1)
+		case builtIn:
+			return nil, fmt.Errorf("line %d: preset %s is built in", i+1, name)
+		case twice:
+			return nil, fmt.Errorf("line %d: preset %s is defined twice", i+1, name)
+		}
+		presets[name] = strings.Fields(args)
+	}
+	return presets, nil
+}
+
+// sharedArguments returns the flags set on the command line but those of the
+// mode, e.g. -batch, as arguments for the runs of the mode.
+func sharedArguments(flags *flag.FlagSet, mode ...string) []string {
+	shared := []string{}
+	flags.Visit(func(f *flag.Flag) {
+		for _, name := range mode {
+			if f.Name == name {
+				return
+			}
+		}
+		shared = append(shared, "-"+f.Name+"="+f.Value.String())
+	})
+	return shared
+}
+
+// argumentValue records the values of a flag given in parsed arguments.
+type argumentValue struct {
+	name   string
+	isBool bool
+	values *[][2]string
+}
+
+func (v argumentValue) String() string { return "" }
+
+func (v argumentValue) IsBoolFlag() bool { return v.isBool }
+
+func (v argumentValue) Set(value string) error {
+	*v.values = append(*v.values, [2]string{v.name, value})
+	return nil
+}
+
+// parseArguments parses the arguments with the flags of the command line
+// and returns the flag names and values in order, without setting the flags.
+func parseArguments(flags *flag.FlagSet, args []string) ([][2]string, error) {
+	values := [][2]string{}
+	arguments := flag.NewFlagSet("", flag.ContinueOnError)
+	arguments.SetOutput(io.Discard)
+	flags.VisitAll(func(f *flag.Flag) {
+		b, ok := f.Value.(interface{ IsBoolFlag() bool })
+		arguments.Var(argumentValue{f.Name, ok && b.IsBoolFlag(), &values}, f.Name, "")
+	})
+	if err := arguments.Parse(args); err != nil {
+		return nil, err
+	}
+	if arguments.NArg() > 0 {
+		return nil, fmt.Errorf("%s isn't a flag", arguments.Arg(0))
+	}
+	return values, nil
+}
+
+// applyUserPreset sets the flags from the arguments of the user preset that
+// are neither given on the command line nor in the config file. A -preset in
+// the arguments is the preset it is based on, a built-in or another user
+// preset, and the flag is set to the built-in preset or cleared.
+func applyUserPreset(flags *flag.FlagSet, name string, presets map[string][]string) error {
+	seen := map[string]bool{}
+	given := map[string]bool{}
+	flags.Visit(func(f *flag.Flag) { given[f.Name] = true })
+	for {
+		if seen[name] {
+			return fmt.Errorf("preset %s is based on itself", name)
+		}
+		seen[name] = true
+		values, err := parseArguments(flags, presets[name])
+		if err != nil {
+			return fmt.Errorf("preset %s: %s", name, err)
+		}
+		base := ""
+		for _, value := range values {
+			switch {
+			case value[0] == "preset":
+				base = value[1]
+			case value[0] == "config" || value[0] == "f" || value[0] == "batch":
+				return fmt.Errorf("preset %s: -%s can't be used in a preset", name, value[0])
+			case !given[value[0]]:
+				if err := flags.Set(value[0], value[1]); err != nil {
+					return fmt.Errorf("preset %s: wrong value for %s: %s", name, value[0], err)
+				}
+			}
+		}
+		for _, value := range values {
+			given[value[0]] = true
+		}
+		if _, ok := presets[base]; !ok {
+			return flags.Set("preset", base)
+		}
+		name = base
+	}
+}
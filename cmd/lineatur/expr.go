@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/maptry/lineatur"
+)
+
+// lengthUnits are the units of -unit with their size in mm.
+var lengthUnits = map[string]float64{
+	"mm": 1,
+	"cm": 10,
+	"in": 25.4,
+	"pt": 25.4 / 72,
+}
+
+// dimensionVariables returns the variables usable in dimension expressions:
+// width and height of every paper size (a4w, a4h, letterw, ...) and of the
+// chosen paper (pw, ph), in the unit of the expressions.
+func dimensionVariables(paperSize lineatur.PaperSize, unit float64) map[string]float64 {
+	vars := map[string]float64{"pw": paperSize.Width / unit, "ph": paperSize.Height / unit}
+	for name, size := range lineatur.PaperSizes {
+		vars[strings.ToLower(name)+"w"] = size.Width / unit
+		vars[strings.ToLower(name)+"h"] = size.Height / unit
+	}
+	return vars
+}
+
+// inMM converts a length in the unit to mm, rounded to a millionth of a mm
+// against float noise like 8.5 in = 215.89999999999998 mm.
+func inMM(v, unit float64) float64 {
+	if unit == 1 {
+		return v
+	}
+	return math.Round(v*unit*1e6) / 1e6
+}
+
+// evalDimension evaluates a dimension expression in the unit and returns it
+// in mm.
+func evalDimension(s string, vars map[string]float64, unit float64) (float64, error) {
+	v, err := evalExpression(s, vars)
+	return inMM(v, unit), err
+}
+
+// evalExpression evaluates an arithmetic expression with +, -, *, /,
+// parentheses, numbers and variables.
+func evalExpression(s string, vars map[string]float64) (float64, error) {
+	if strings.Contains(s, ",") {
+		return 0, fmt.Errorf("decimal comma in %s, use a point", s)
+	}
+	p := &exprParser{s: s, vars: vars}
+	p.next()
+	v, err := p.expr()
+	if err != nil {
+		return 0, err
+	}
+	if p.tok != "" {
+		return 0, fmt.Errorf("unexpected %s in %s", p.tok, s)
+	}
+	return v, nil
+}
+
+type exprParser struct {
+	s    string
+	pos  int
+	tok  string // current token, empty at the end
+	vars map[string]float64
+}
+
+// next reads the next token: a number, a variable name or an operator.
+func (p *exprParser) next() {
+	for p.pos < len(p.s) && p.s[p.pos] == ' ' {
+		p.pos++
+	}
+	start := p.pos
+	switch {
+	case p.pos == len(p.s):
+	case unicode.IsDigit(rune(p.s[p.pos])) || p.s[p.pos] == '.':
+		for p.pos < len(p.s) && (unicode.IsDigit(rune(p.s[p.pos])) || p.s[p.pos] == '.') {
+			p.pos++
+		}
+	case unicode.IsLetter(rune(p.s[p.pos])):
+		for p.pos < len(p.s) && (unicode.IsLetter(rune(p.s[p.pos])) || unicode.IsDigit(rune(p.s[p.pos]))) {
+			p.pos++
+		}
+	default:
+		p.pos++
+	}
+	p.tok = p.s[start:p.pos]
+}
+
+// expr = term {("+" | "-") term}
+func (p *exprParser) expr() (float64, error) {
+	v, err := p.term()
+	for err == nil && (p.tok == "+" || p.tok == "-") {
+		op := p.tok
+		p.next()
+		var w float64
+		if w, err = p.term(); op == "+" {
+			v += w
+		} else {
+			v -= w
+		}
+	}
+	return v, err
+}
+
+// term = factor {("*" | "/") factor}
+func (p *exprParser) term() (float64, error) {
+	v, err := p.factor()
+	for err == nil && (p.tok == "*" || p.tok == "/") {
+		op := p.tok
+		p.next()
+		var w float64
+		if w, err = p.factor(); err != nil {
+			break
+		}
+		if op == "*" {
+			v *= w
+		} else if w == 0 {
+			err = fmt.Errorf("division by zero in %s", p.s)
+		} else {
+			v /= w
+		}
+	}
+	return v, err
+}
+
+// factor = number | variable | "(" expr ")" | "-" factor
+func (p *exprParser) factor() (float64, error) {
+	tok := p.tok
+	switch {
+	case tok == "":
+		return 0, fmt.Errorf("unexpected end of %s", p.s)
+	case tok == "-":
+		p.next()
+		v, err := p.factor()
+		return -v, err
+	case tok == "(":
+		p.next()
+		v, err := p.expr()
+		if err != nil {
+			return 0, err
+		}
+		if p.tok != ")" {
+			return 0, fmt.Errorf("missing ) in %s", p.s)
+		}
+		p.next()
+		return v, nil
+	case unicode.IsDigit(rune(tok[0])) || tok[0] == '.':
+		p.next()
+		return strconv.ParseFloat(tok, 64)
+	case unicode.IsLetter(rune(tok[0])):
+		p.next()
+		v, ok := p.vars[tok]
+		if !ok {
+			return 0, fmt.Errorf("unknown variable %s in %s", tok, p.s)
+		}
+		return v, nil
+	}
+	return 0, fmt.Errorf("unexpected %s in %s", tok, p.s)
+}
+
+// parseDimensions parses colon separated dimension expressions in the unit
+// into mm.
+func parseDimensions(s string, vars map[string]float64, unit float64) ([]float64, error) {
+	if s == "" {
+		return nil, nil
+	}
+	values := []float64{}
+	for _, e := range strings.Split(s, ":") {
+		v, err := evalDimension(e, vars, unit)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
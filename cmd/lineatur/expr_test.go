@@ -0,0 +1,81 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestEvalExpression(t *testing.T) {
+	vars := map[string]float64{"pw": 210, "ph": 297, "a5w": 148}
+	tests := []struct {
+		s    string
+		want float64
+		err  string // part of the error, empty = no error
+	}{
+		{s: "12", want: 12},
+		{s: " 2.5 ", want: 2.5},
+		{s: "1+2*3", want: 7},
+		{s: "10-4-3", want: 3},
+		{s: "12/3/2", want: 2},
+		{s: "2*3+4*5", want: 26},
+		{s: "(1+2)*3", want: 9},
+		{s: "((7))", want: 7},
+		{s: "2*(3+(4-1))/4", want: 3},
+		{s: "-3", want: -3},
+		{s: "--3", want: 3},
+		{s: "-3*-2", want: 6},
+		{s: "4--2", want: 6},
+		{s: "-(2+3)", want: -5},
+		{s: "pw/2", want: 105},
+		{s: "(ph-pw)/2-a5w/4", want: 6.5},
+		{s: "1/0", err: "division by zero in 1/0"},
+		{s: "pw/(2-2)", err: "division by zero"},
+		{s: "qw/2", err: "unknown variable qw in qw/2"},
+		{s: "10mm", err: "unexpected mm in 10mm"},
+		{s: "2in", err: "unexpected in in 2in"},
+		{s: "(1+2", err: "missing ) in (1+2"},
+		{s: "1+", err: "unexpected end of 1+"},
+		{s: "", err: "unexpected end of "},
+		{s: "2)", err: "unexpected ) in 2)"},
+		{s: "*2", err: "unexpected * in *2"},
+		{s: "1..2", err: "invalid syntax"},
+		{s: "2,5", err: "decimal comma in 2,5"},
+	}
+	for _, test := range tests {
+		got, err := evalExpression(test.s, vars)
+		switch {
+		case test.err == "" && err != nil:
+			t.Errorf("evalExpression(%q) failed: %v", test.s, err)
+		case test.err == "" && got != test.want:
+			t.Errorf("evalExpression(%q) = %g, want %g", test.s, got, test.want)
+		case test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)):
+			t.Errorf("evalExpression(%q) = %g, %v, want error %q", test.s, got, err, test.err)
+		}
+	}
+}
+
+func TestEvalDimension(t *testing.T) {
+	tests := []struct {
+		s    string
+		unit string
+		want float64 // in mm
+	}{
+		{s: "12", unit: "mm", want: 12},
+		{s: "1.2", unit: "cm", want: 12},
+		{s: "8.5", unit: "in", want: 215.9},
+		{s: "1/4", unit: "in", want: 6.35},
+		{s: "72", unit: "pt", want: 25.4},
+		{s: "pw/2", unit: "in", want: 105},
+	}
+	for _, test := range tests {
+		unit := lengthUnits[test.unit]
+		vars := map[string]float64{"pw": 210 / unit}
+		got, err := evalDimension(test.s, vars, unit)
+		if err != nil {
+			t.Errorf("evalDimension(%q, %s) failed: %v", test.s, test.unit, err)
+		} else if math.Abs(got-test.want) > 1e-9 {
+			t.Errorf("evalDimension(%q, %s) = %g mm, want %g mm", test.s, test.unit, got, test.want)
+		}
+	}
+}
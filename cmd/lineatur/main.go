@@ -0,0 +1,1254 @@
+package main
+
+import (
+	"archive/zip"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/maptry/lineatur"
+)
+
+func usage() {
+	flag.PrintDefaults()
+	fmt.Fprintf(os.Stderr, "Line proportions: no argument = just one line\n")
+	fmt.Fprintf(os.Stderr, "Line proportions: num = two lines (the value doesn't matter)\n")
+	fmt.Fprintf(os.Stderr, "Line proportions: num[:num...]\n")
+	fmt.Fprintf(os.Stderr, "Line proportions: the zones from the top down, the letters sit on the bottom line of the middle zone, with -hanging they hang from its top line\n")
+	fmt.Fprintf(os.Stderr, "Compare: label=num[:num...][,label=num[:num...]...] blocks stacked on the page, each with its label and line proportions\n")
+	fmt.Fprintf(os.Stderr, "Zone colors: color[,color...] tints of the zones, an empty color leaves the zone white\n")
+	fmt.Fprintf(os.Stderr, "Zone styles: style[:style...] %s lines at the top and bottom of the zones, the last style is used for the remaining lines\n", strings.Join(lineatur.LineStyles, ", "))
+	fmt.Fprintf(os.Stderr, "Line styles: num=style[,num=style...] the style of single lines, 0 is the top line, the other lines keep their zone style,\n")
+	fmt.Fprintf(os.Stderr, "    or style[:style...] the styles of the lines from the top like -zone-styles, dash and dot are short for dashed and dotted\n")
+	fmt.Fprintf(os.Stderr, "Line widths: num[:num...] the width in mm of all lines, or of the lines from the top with the thinnest for borders, slants and guides\n")
+	fmt.Fprintf(os.Stderr, "Sub-lines: num=num[,num=num...] the zone, 0 is the top zone, and the number of thin dotted lines dividing it evenly\n")
+	fmt.Fprintf(os.Stderr, "Zone gaps: num[:num...] unruled gaps in mm between the zones of the line proportions, 0 = no gap\n")
+	fmt.Fprintf(os.Stderr, "Zone dimensions: \"first\" or \"all\" the lines with zones labeled with their heights\n")
+	fmt.Fprintf(os.Stderr, "Slanted helper lines: \"num:num\" the angle from 1 to 179 and number per line of slanted helper lines, above 90 they lean left,\n")
+	fmt.Fprintf(os.Stderr, "    or \"num:@num\" the angle and the spacing in mm, the slants at the ends are cut at the borders\n")
+	fmt.Fprintf(os.Stderr, "Slants overlay: the slanted helper lines are put on a separate page following the lines\n")
+	fmt.Fprintf(os.Stderr, "Warm-up: \"wave:num:num\" or \"loops:num:num\" the amplitude and wavelength in mm of a tracing pattern in the x-height zone of the first line\n")
+	fmt.Fprintf(os.Stderr, "Grid: \"dots:num\", \"squares:num\" or \"seyes:num\" a lattice of dots, squares or squares with three fine lines in between of num mm from the top left corner of the margins, the proportions, slants and guides are ignored,\n")
+	fmt.Fprintf(os.Stderr, "    \"dots:num:num\" sets the dot diameter in mm, the default is twice -lw,\n")
+	fmt.Fprintf(os.Stderr, "    \"tianzige:num\", \"mizige:num\" or \"genko:num\" whole cells of num mm for CJK characters with a dotted cross, also diagonals,\n")
+	fmt.Fprintf(os.Stderr, "    or in columns with a narrow furigana column in between\n")
+	fmt.Fprintf(os.Stderr, "    \"squares:num:num\" draws a major line every num cells with -major-lw and -major-color\n")
+	fmt.Fprintf(os.Stderr, "Staff: five equally spaced lines per line height, the proportions, slants and guides are ignored\n")
+	fmt.Fprintf(os.Stderr, "Page margins: num:num:num:num top, right, bottom and left margins of the page in mm\n")
+	fmt.Fprintf(os.Stderr, "Safe area: num:num:num:num top, right, bottom and left non-printable borders of the printer in mm\n")
+	fmt.Fprintf(os.Stderr, "Printers: %s\n", strings.Join(lineatur.PrinterNames(), ", "))
+	fmt.Fprintf(os.Stderr, "Paper and poster sizes: name or numxnum the width and height in mm or -unit, e.g. 120x180 or 120.5x180\n")
+	fmt.Fprintf(os.Stderr, "Poster: name or numxnum the size in mm of a poster tiled across pages of the paper size, glued together at the registration marks\n")
+	fmt.Fprintf(os.Stderr, "Crop marks: the media is larger than the paper size by the bleed and the marks on every side, the trim box of the PDF is the paper size\n")
+	fmt.Fprintf(os.Stderr, "Calibration: print the page of -calibrate without fit to page and measure its square, with w mm wide and h mm high -scale 100/w:100/h\n")
+	fmt.Fprintf(os.Stderr, "    scales every sheet around its center so the square measures 100 mm, the crop marks and the footer too\n")
+	fmt.Fprintf(os.Stderr, "Spread: two pages of the paper size side by side, the lines and slants continue across the gutter, the margins are those of the spread\n")
+	fmt.Fprintf(os.Stderr, "N-up: 2 pages of half the paper side by side on the paper turned to landscape, e.g. A5 on A4, or 4 quarter pages in two rows, dashed lines mark the cuts\n")
+	fmt.Fprintf(os.Stderr, "Exemplar: text or %s written light in -font on the first line of every block, column and compared block, sized to the x-height\n", strings.Join(lineatur.ExemplarNames(), ", "))
+	fmt.Fprintf(os.Stderr, "Trace: text written into the lines in -trace-color, its x-height is the middle zone and it sits on the baseline, the words wrap at the end of the line,\n")
+	fmt.Fprintf(os.Stderr, "    a dotted or outline TrueType font of -trace-font is embedded, the core fonts of -font are written light\n")
+	fmt.Fprintf(os.Stderr, "Watermark: text or image file centered behind the ruling of every page at -watermark-pos, as wide as -watermark-width, turned by -watermark-angle,\n")
+	fmt.Fprintf(os.Stderr, "    the text is written in -font, the PNG output rasterizes the image and the DXF output leaves it out\n")
+	fmt.Fprintf(os.Stderr, "Booklet: num pages rounded up to a multiple of 4, printed double-sided in folding order, fold and staple in the middle\n")
+	fmt.Fprintf(os.Stderr, "Presets: the arguments they stand for, given arguments override them\n")
+	for _, name := range lineatur.PresetNames() {
+		fmt.Fprintf(os.Stderr, "    %-12s %s\n", name, presetArguments(lineatur.Presets[name]))
+	}
+	if filename, err := userPresetsFile(); err == nil {
+		fmt.Fprintf(os.Stderr, "User presets: name = arguments one per line in %s, e.g. my-copperplate = -preset copperplate -s 55:12 -lh 8\n", filename)
+		for _, name := range userPresetNames() {
+			fmt.Fprintf(os.Stderr, "    %-12s %s\n", name, strings.Join(userPresets[name], " "))
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Fonts: %s\n", strings.Join(lineatur.Fonts, ", "))
+	fmt.Fprintf(os.Stderr, "Order: layer[,layer...] the layers from the bottom up, layers left out aren't drawn, default %s\n", strings.Join(lineatur.Layers, ","))
+	fmt.Fprintf(os.Stderr, "Numbers: proportions, lengths, the grid and the warm-up take decimals with a point, e.g. 3.5, a decimal comma is rejected\n")
+	fmt.Fprintf(os.Stderr, "Dimensions: -lh, -ls, -grow, -m, -safe, -zone-gap and -cornell take expressions with +, -, *, / and parentheses,\n")
+	fmt.Fprintf(os.Stderr, "    pw and ph are the width and height of the paper, a4w, a4h, letterw... those of the paper sizes\n")
+	fmt.Fprintf(os.Stderr, "Units: with -unit cm, in or pt all lengths given as arguments are in the unit instead of mm, also sizes, the grid, -lw and the number flags,\n")
+	fmt.Fprintf(os.Stderr, "    the variables of the expressions too, the defaults, presets, layout files and -fontsize stay as they are\n")
+	fmt.Fprintf(os.Stderr, "Colors: num:num:num the red, green and blue components from 0 to 255, #rrggbb in hex or a name: %s\n", strings.Join(colorNamesSorted(), ", "))
+	fmt.Fprintf(os.Stderr, "Line colors: num=color[,num=color...] the color of single lines, 0 is the top line, the other lines keep -color\n")
+	fmt.Fprintf(os.Stderr, "Config: a JSON object of flag names without - and their values, e.g. {\"p\": \"3:2:3\", \"s\": \"55:10\", \"lw\": 0.2},\n")
+	fmt.Fprintf(os.Stderr, "    flags on the command line override the file, the file overrides a preset like flags do\n")
+	fmt.Fprintf(os.Stderr, "Layout: {\"Pages\": [{...}, ...]} a JSON object of pages with the fields of lineatur.Config, e.g. {\"Proportions\": [2, 1, 2], \"LineHeight\": 10},\n")
+	fmt.Fprintf(os.Stderr, "    overriding the flags, and \"Regions\": [{\"Top\": mm, \"Height\": mm, ...}] parts of a page ruled with their own fields,\n")
+	fmt.Fprintf(os.Stderr, "    \"Left\": mm and \"Width\": mm for a part beside another, -regions [{...}, ...] the regions of every page without a file\n")
+	fmt.Fprintf(os.Stderr, "Zip: the files of all formats are named after the archive, e.g. sheet.pdf and sheet.svg in sheet.zip\n")
+	fmt.Fprintf(os.Stderr, "Batch: one line per sheet with its arguments and -o, e.g. -preset kurrent -lh 9 -o kurrent.pdf, lines starting with # are skipped,\n")
+	fmt.Fprintf(os.Stderr, "    the other arguments are shared by the jobs and the jobs override them\n")
+	fmt.Fprintf(os.Stderr, "Serve: GET /lineatur.pdf, .svg, .dxf or .png with the flags as parameters, e.g. /lineatur.pdf?preset=copperplate&lh=8&ps=Letter,\n")
+	fmt.Fprintf(os.Stderr, "    parameters without a value set a boolean flag, the other arguments are the defaults, file flags like -config and -f are refused,\n")
+	fmt.Fprintf(os.Stderr, "    at most %d pages, %d dpi, %d mm paper and poster sizes and %g million pixels of png pages\n", serveMaxPages, serveMaxDPI, serveMaxSize, serveMaxPixels/1e6)
+	fmt.Fprintf(os.Stderr, "Bench: the other arguments are ignored, the fastest of %d runs of each layout and format is reported\n", benchRuns)
+	fmt.Fprintf(os.Stderr, "Validate: all problems of the arguments are reported without writing the output file\n")
+	fmt.Fprintf(os.Stderr, "examples:\n")
+	fmt.Fprintf(os.Stderr, "    -p 2:1:2 -s 60:10  Deutsche Kurrentschrift\n")
+	fmt.Fprintf(os.Stderr, "    -p 1:1:1           Sütterlinschrift\n")
+	fmt.Fprintf(os.Stderr, "    -p 2:3:2 -s 75:10  Offenbacher Schrift\n")
+	fmt.Fprintf(os.Stderr, "    -p 3:4:3           Offenbacher Schrift, Lateinische Ausgangsschrift\n")
+	fmt.Fprintf(os.Stderr, "    -p 3:2:3 -s 52:10  Copperplate\n")
+	fmt.Fprintf(os.Stderr, "    -p 3:4:3 -fade-right 0.6  Model at the left fading out to copy it\n")
+	fmt.Fprintf(os.Stderr, "    -p 3:4:3 -lh 6 -grow 1  Lines growing from 6 mm for progressive practice\n")
+	fmt.Fprintf(os.Stderr, "    -p 3:2:3 -s 55:10 -lh 15 -loop-guides 8  Loops of l and g in Copperplate\n")
+	fmt.Fprintf(os.Stderr, "    -preset italic -order bg,lines,shade,slants  Zone tint over the lines\n")
+	fmt.Fprintf(os.Stderr, "    -p 1:2:1 -hanging  Devanagari with the shirorekha\n")
+	fmt.Fprintf(os.Stderr, "    -ps A5 -landscape  Same as -ps A5L and -ps A5 -orient landscape\n")
+	fmt.Fprintf(os.Stderr, "    -color 160:160:160 -scolor 200:200:255  Faint gray lines and bluish slants\n")
+	fmt.Fprintf(os.Stderr, "    -p 3:4:3 -color lightblue -line-colors 2=black -scolor lightgray  Black baseline, light blue guides\n")
+	fmt.Fprintf(os.Stderr, "    -p 1:1 -style 1=dashed  School paper with a dashed midline\n")
+	fmt.Fprintf(os.Stderr, "    -p 3:4:3 -pages 50  Practice pad of 50 pages\n")
+	fmt.Fprintf(os.Stderr, "    -grid dots:5:0.4 -color gray  Dot grid for bullet journals\n")
+	fmt.Fprintf(os.Stderr, "    -grid squares:5    Squared math paper\n")
+	fmt.Fprintf(os.Stderr, "    -grid mizige:15 -color red  Chinese practice squares\n")
+	fmt.Fprintf(os.Stderr, "    -vertical -p 1 -cells -lh 12 -ls 4  Columns of squares for Japanese and Chinese written top down\n")
+	fmt.Fprintf(os.Stderr, "    -grid squares:1:10 -lw 0.1 -color lightblue -major-color blue  Millimeter paper\n")
+	fmt.Fprintf(os.Stderr, "    -preset gregg -ps 152x229  Gregg shorthand on a steno pad\n")
+	fmt.Fprintf(os.Stderr, "    -preset seyes -margin-line 40  Séyès school paper with the margin line at 40 mm\n")
+	fmt.Fprintf(os.Stderr, "    -p 3:4:3 -baseline -baseline-color 200:0:0  Red baseline for beginners\n")
+	fmt.Fprintf(os.Stderr, "    -title \"Name:            Date:\" -font Times  Header for the name of the student\n")
+	fmt.Fprintf(os.Stderr, "    -header Name,Date,Topic  Fields to fill in above the lines of a worksheet\n")
+	fmt.Fprintf(os.Stderr, "    -preset italic -footer  Page numbers and the ruling at the bottom to tell test sheets apart\n")
+	fmt.Fprintf(os.Stderr, "    -p 3:4:3 -shade 1  Gray x-height band for beginners\n")
+	fmt.Fprintf(os.Stderr, "    -p 3:4:3 -shade 0-1 -shade-color lightblue -zone-opacity 0.5  Light blue band from the ascender line to the baseline\n")
+	fmt.Fprintf(os.Stderr, "    -config copperplate.json -lw 0.2  Saved settings with a thinner line\n")
+	fmt.Fprintf(os.Stderr, "    -holes 4 -m 5:15:15:20  Marks of the 4 holes of a ring binder in the left margin\n")
+	fmt.Fprintf(os.Stderr, "    -pages 10 -duplex -holes 4 -m 5:5:15:25  Double-sided pad, the binding margin on the right of the backs\n")
+	fmt.Fprintf(os.Stderr, "    -ps Letter -holes 3 -m 5:15:15:25 -margin-line 8 -margin-color blue  US filler paper\n")
+	fmt.Fprintf(os.Stderr, "    -preset copperplate -mirror  Copperplate for left-handers, slanted at 125°\n")
+	fmt.Fprintf(os.Stderr, "    -s 60:@8           A slant every 8 mm on every paper size\n")
+	fmt.Fprintf(os.Stderr, "    -s 55:@7.5 -slants-through  Slants running down the whole page\n")
+	fmt.Fprintf(os.Stderr, "    -staff -lh 8 -ls 12  Music manuscript paper\n")
+	fmt.Fprintf(os.Stderr, "    -staff -grand-staff -rows 10  Five piano systems per page\n")
+	fmt.Fprintf(os.Stderr, "    -p 3:4:3 -lw 0.5:0.2:0.5:0.2  Heavy cap line and baseline\n")
+	fmt.Fprintf(os.Stderr, "    -p 3:4:3 -sub 0=1,2=1  Midlines in the ascender and descender zones\n")
+	fmt.Fprintf(os.Stderr, "    -p 3:4:3 -nib 2.5 -nib-ladder  Broad-edge italic 3, 4 and 3 nib widths high\n")
+	fmt.Fprintf(os.Stderr, "    -p 3:4:3 -lines 12  Twelve lines filling the page, whatever the paper and margins\n")
+	fmt.Fprintf(os.Stderr, "    -preset kurrent -distribute  No gap below the last line, the spacing takes it up\n")
+	fmt.Fprintf(os.Stderr, "    -cornell 60:50 -lh 8  Cornell notes with a cue column and a summary box\n")
+	fmt.Fprintf(os.Stderr, "    -regions '[{\"Top\": 150, \"Height\": 130, \"Proportions\": [3, 4, 3]}]'  Top half blank for a drawing, lines below\n")
+	fmt.Fprintf(os.Stderr, "    -batch sheets.txt -ps A5  Every sheet of the file on A5, four at a time on four cores\n")
+	fmt.Fprintf(os.Stderr, "    -serve :8080 -footer  Sheets for a web page, all with the footer\n")
+	fmt.Fprintf(os.Stderr, "    -unit in -ps 8.5x11 -lh 0.375 -ls 0.25 -m 0.5:0.5:0.5:1.25  Inch worksheet without converting\n")
+	fmt.Fprintf(os.Stderr, "    -i -lw 0.2         Questions for the paper, script and lines, thin lines for all answers\n")
+	fmt.Fprintf(os.Stderr, "    -preset kurrent -deterministic -o kurrent.pdf  Same bytes on every run for version control\n")
+	fmt.Fprintf(os.Stderr, "    -ps A5 -crop-marks -bleed 3 -vignette 0.3  A5 pads cut by the print shop from larger sheets\n")
+	fmt.Fprintf(os.Stderr, "    -nup 2 -pages 50 -p 3:4:3  A5 practice pad of 50 pages printed 2-up on 25 A4 sheets\n")
+	fmt.Fprintf(os.Stderr, "    -preset dyslexia -baseline-lw 0.8  Pale tints taking turns, wide gaps and a heavy baseline\n")
+	fmt.Fprintf(os.Stderr, "    -preset copperplate -lh 9 -qr  QR code of the arguments to print the same sheet again\n")
+	fmt.Fprintf(os.Stderr, "    -p 3:4:3 -line-numbers 5  Every fifth line numbered to refer to it in class\n")
+	fmt.Fprintf(os.Stderr, "    -preset lateinisch -exemplar alphabet  The alphabet at the top to copy on the lines below\n")
+	fmt.Fprintf(os.Stderr, "    -preset lateinisch -trace \"The quick brown fox jumps over the lazy dog.\" -trace-font dots.ttf  Pangram to trace\n")
+	fmt.Fprintf(os.Stderr, "    -watermark \"Elm Street School\" -watermark-angle 45  School name across the page\n")
+	fmt.Fprintf(os.Stderr, "    -watermark-image logo.png -watermark-width 60 -watermark-pos 180:20 -watermark-opacity 0.3  Logo in the corner\n")
+	fmt.Fprintf(os.Stderr, "    -calibrate -scale 100/98  Check the correction of a printer shrinking by 2 %%\n")
+	fmt.Fprintf(os.Stderr, "    -poster 420x594    A2 poster out of A4 pages\n")
+	fmt.Fprintf(os.Stderr, "    -cols 3 -gutter 8 -lh 8  Three columns for vocabulary drills\n")
+	fmt.Fprintf(os.Stderr, "    -spread -spread-gutter 30 -p 2:3:2 -s 75:40  Lines across a journal spread\n")
+	fmt.Fprintf(os.Stderr, "    -compare Kurrent=2:1:2,Copperplate=3:2:3\n")
+	fmt.Fprintf(os.Stderr, "    -lh \"ph/30\" -m \"a4h*0.05:15:15:5\"\n")
+	fmt.Fprintf(os.Stderr, "    -format pdf,svg,dxf -zip -o sheet.zip\n")
+	fmt.Fprintf(os.Stderr, "    -o sheet.svg       SVG for design tools, the format follows the extension\n")
+	fmt.Fprintf(os.Stderr, "    -format svg -o -   SVG preview on stdout\n")
+}
+
+// commandLine joins the arguments to a command for the shell, quoting those
+// with spaces or other special characters.
+func commandLine(args []string) string {
+	quoted := []string{}
+	for _, arg := range args {
+		if strings.Trim(arg, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_=.,:/@%+") != "" {
+			arg = "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+		}
+		quoted = append(quoted, arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// presetArguments returns the command line arguments a preset stands for.
+func presetArguments(p lineatur.Preset) string {
+	join := func(values []float64) string {
+		strs := []string{}
+		for _, v := range values {
+			strs = append(strs, strconv.FormatFloat(v, 'g', -1, 64))
+		}
+		return strings.Join(strs, ":")
+	}
+	joinColors := func(colors []lineatur.Color) string {
+		strs := []string{}
+		for _, c := range colors {
+			if c == lineatur.White {
+				strs = append(strs, "")
+			} else {
+				strs = append(strs, fmt.Sprintf("%d:%d:%d", c.R, c.G, c.B))
+			}
+		}
+		return strings.Join(strs, ",")
+	}
+	args := []string{}
+	if len(p.Proportions) > 0 {
+		args = append(args, "-p "+join(p.Proportions))
+	}
+	if len(p.Slants) > 0 {
+		args = append(args, "-s "+join(p.Slants))
+	}
+	if p.LineHeight > 0 {
+		args = append(args, "-lh "+join([]float64{p.LineHeight}))
+	}
+	if p.LineSpacing > 0 {
+		args = append(args, "-ls "+join([]float64{p.LineSpacing}))
+	}
+	if len(p.ZoneColors) > 0 {
+		args = append(args, "-zone-colors "+joinColors(p.ZoneColors))
+	}
+	if p.Grid != "" {
+		args = append(args, "-grid "+p.Grid+":"+join([]float64{p.GridSpacing}))
+	}
+	if p.MarginLine > 0 {
+		args = append(args, "-margin-line "+join([]float64{p.MarginLine}))
+	}
+	if p.CenterLine {
+		args = append(args, "-center-line")
+	}
+	if len(p.RowTints) > 0 {
+		args = append(args, "-row-tints "+joinColors(p.RowTints))
+	}
+	if p.Baseline {
+		args = append(args, "-baseline")
+	}
+	return strings.Join(args, " ")
+}
+
+// colorNames are the colors given by name.
+var colorNames = map[string]lineatur.Color{
+	"black":     {R: 0, G: 0, B: 0},
+	"gray":      {R: 128, G: 128, B: 128},
+	"lightgray": {R: 200, G: 200, B: 200},
+	"white":     {R: 255, G: 255, B: 255},
+	"red":       {R: 220, G: 40, B: 40},
+	"blue":      {R: 40, G: 80, B: 200},
+	"lightblue": {R: 170, G: 200, B: 240},
+	"green":     {R: 40, G: 150, B: 60},
+}
+
+func colorNamesSorted() []string {
+	names := []string{}
+	for name := range colorNames {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// parseLineColors parses comma separated index=color entries into the colors
+// of all lines, the lines not given keep the color of the lines.
+func parseLineColors(s string, lineColor lineatur.Color, lines int) ([]lineatur.Color, error) {
+	colors := make([]lineatur.Color, lines)
+	for i := range colors {
+		colors[i] = lineColor
+	}
+	for _, entry := range strings.Split(s, ",") {
+		_index, _color, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("line color %s has no index", entry)
+		}
+		index, err := strconv.Atoi(_index)
+		if err != nil || index < 0 || index >= lines {
+			return nil, fmt.Errorf("line %s out of interval 0-%d", _index, lines-1)
+		}
+		if colors[index], err = parseColor(_color); err != nil {
+			return nil, err
+		}
+	}
+	return colors, nil
+}
+
+// parseColor parses a color given as R:G:B, #RRGGBB or name.
+func parseColor(s string) (lineatur.Color, error) {
+	if c, ok := colorNames[strings.ToLower(s)]; ok {
+		return c, nil
+	}
+	if hex := strings.TrimPrefix(s, "#"); hex != s {
+		if len(hex) != 6 {
+			return lineatur.Color{}, fmt.Errorf("color %s needs six hex digits", s)
+		}
+		v, err := strconv.ParseUint(hex, 16, 32)
+		if err != nil {
+			return lineatur.Color{}, err
+		}
+		return lineatur.Color{R: int(v >> 16), G: int(v >> 8 & 0xff), B: int(v & 0xff)}, nil
+	}
+	values, err := parseMultiUint64(s)
+	if err != nil {
+		return lineatur.Color{}, err
+	}
+	if len(values) != 3 {
+		return lineatur.Color{}, fmt.Errorf("color %s needs three components", s)
+	}
+	for _, v := range values {
+		if v > 255 {
+			return lineatur.Color{}, fmt.Errorf("color component %g out of interval 0-255", v)
+		}
+	}
+	return lineatur.Color{R: int(values[0]), G: int(values[1]), B: int(values[2])}, nil
+}
+
+// parseColors parses a comma separated list of colors, empty entries are white.
+func parseColors(s string) ([]lineatur.Color, error) {
+	if s == "" {
+		return nil, nil
+	}
+	colors := []lineatur.Color{}
+	for _, c := range strings.Split(s, ",") {
+		if c == "" {
+			colors = append(colors, lineatur.White)
+			continue
+		}
+		color, err := parseColor(c)
+		if err != nil {
+			return nil, err
+		}
+		colors = append(colors, color)
+	}
+	return colors, nil
+}
+
+func parseMultiUint64(s string) ([]float64, error) {
+	if s == "" {
+		return nil, nil
+	}
+	strs := strings.Split(s, ":")
+	values := []float64{}
+	for _, m := range strs {
+		u, err := strconv.ParseUint(m, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, float64(u))
+	}
+	return values, nil
+}
+
+// parseMultiFloat parses colon separated decimals of at least 0, e.g. 3.5:2.
+func parseMultiFloat(s string) ([]float64, error) {
+	if s == "" {
+		return nil, nil
+	}
+	values := []float64{}
+	for _, m := range strings.Split(s, ":") {
+		if strings.Contains(m, ",") {
+			return nil, fmt.Errorf("decimal comma in %s, use a point", m)
+		}
+		f, err := strconv.ParseFloat(m, 64)
+		if err != nil || !(f >= 0) || math.IsInf(f, 1) {
+			return nil, fmt.Errorf("%s is no number of at least 0", m)
+		}
+		values = append(values, f)
+	}
+	return values, nil
+}
+
+// parseLineStyles sets the styles of single lines given as comma separated
+// index=style, the lines are counted from 0 at the top. The other lines keep
+// their zone style. Without an index the styles of all lines are given like
+// for -zone-styles.
+func parseLineStyles(s string, zoneStyles []string, lines int) ([]string, error) {
+	if !strings.Contains(s, "=") {
+		// the styles of all lines from the top
+		styles := strings.Split(s, ":")
+		for i, style := range styles {
+			styles[i] = styleName(style)
+		}
+		return styles, nil
+	}
+	styles := []string{}
+	for i := 0; i < lines; i++ {
+		styles = append(styles, lineatur.BoundaryStyle(zoneStyles, i))
+	}
+	for _, entry := range strings.Split(s, ",") {
+		_index, style, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("line style %s has no index", entry)
+		}
+		index, err := strconv.Atoi(_index)
+		if err != nil || index < 0 || index >= lines {
+			return nil, fmt.Errorf("line %s out of interval 0-%d", _index, lines-1)
+		}
+		styles[index] = styleName(style)
+	}
+	return styles, nil
+}
+
+// styleName returns the line style of the short names dash and dot.
+func styleName(style string) string {
+	switch style {
+	case "dash":
+		return "dashed"
+	case "dot":
+		return "dotted"
+	}
+	return style
+}
+
+// parseSubLines parses comma separated zone=number entries of sub-lines for
+// the zones of the line proportions.
+func parseSubLines(s string, zones int) ([]int, error) {
+	subLines := make([]int, zones)
+	for _, entry := range strings.Split(s, ",") {
+		_index, _n, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("sub-lines %s have no zone", entry)
+		}
+		index, err := strconv.Atoi(_index)
+		if err != nil || index < 0 || index >= zones {
+			return nil, fmt.Errorf("zone %s out of interval 0-%d", _index, zones-1)
+		}
+		n, err := strconv.Atoi(_n)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("number of sub-lines %s is not a number of at least 0", _n)
+		}
+		subLines[index] = n
+	}
+	return subLines, nil
+}
+
+// parseZoneBand parses a zone or a band first-last of zones of the line
+// proportions.
+func parseZoneBand(s string, zones int) (int, int, error) {
+	_first, _last, ok := strings.Cut(s, "-")
+	if !ok {
+		_last = _first
+	}
+	first, err := strconv.Atoi(_first)
+	if err != nil || first < 0 || first >= zones {
+		return 0, 0, fmt.Errorf("zone %s out of interval 0-%d", _first, zones-1)
+	}
+	last, err := strconv.Atoi(_last)
+	if err != nil || last < first || last >= zones {
+		return 0, 0, fmt.Errorf("zone %s out of interval %d-%d", _last, first, zones-1)
+	}
+	return first, last, nil
+}
+
+// parseCompare parses comma separated label=proportions blocks.
+func parseCompare(s string) ([]lineatur.LabeledProportions, error) {
+	if s == "" {
+		return nil, nil
+	}
+	blocks := []lineatur.LabeledProportions{}
+	for _, b := range strings.Split(s, ",") {
+		label, _proportions, ok := strings.Cut(b, "=")
+		if !ok {
+			return nil, fmt.Errorf("block %s has no proportions", b)
+		}
+		proportions, err := parseMultiFloat(_proportions)
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, lineatur.LabeledProportions{Label: label, Proportions: proportions})
+	}
+	return blocks, nil
+}
+
+// parseSize parses a paper size name or WIDTHxHEIGHT in the unit into mm.
+func parseSize(s string, unit float64) (lineatur.PaperSize, error) {
+	if size, ok := lineatur.PaperSizes[s]; ok {
+		return size, nil
+	}
+	w, h, ok := strings.Cut(s, "x")
+	if !ok {
+		return lineatur.PaperSize{}, fmt.Errorf("unknown size %s, possible values: %s or WIDTHxHEIGHT", s, strings.Join(lineatur.PaperNames(), ", "))
+	}
+	length := func(v string) (float64, error) {
+		l, err := strconv.ParseFloat(v, 64)
+		if err != nil || !(l > 0) || math.IsInf(l, 1) {
+			return 0, fmt.Errorf("%q in size %s is no length greater than 0", v, s)
+		}
+		return inMM(l, unit), nil
+	}
+	width, err := length(w)
+	if err != nil {
+		return lineatur.PaperSize{}, err
+	}
+	height, err := length(h)
+	if err != nil {
+		return lineatur.PaperSize{}, err
+	}
+	return lineatur.PaperSize{Width: width, Height: height}, nil
+}
+
+// writeOutput writes the document to the file, - is stdout.
+func writeOutput(filename string, output func(w io.Writer) error) error {
+	if filename == "-" {
+		return output(os.Stdout)
+	}
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := output(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// zipEntry is a file of the archive written by -zip.
+type zipEntry struct {
+	Name   string
+	Output func(w io.Writer) error
+}
+
+// writeZip writes the entries into a zip archive.
+func writeZip(w io.Writer, entries []zipEntry) error {
+	archive := zip.NewWriter(w)
+	for _, entry := range entries {
+		f, err := archive.CreateHeader(&zip.FileHeader{Name: entry.Name, Method: zip.Deflate, Modified: time.Now()})
+		if err != nil {
+			return err
+		}
+		if err := entry.Output(f); err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+func main() {
+	if filename, err := userPresetsFile(); err == nil {
+		if userPresets, err = readUserPresets(filename); err != nil {
+			fmt.Fprintf(os.Stderr, "reading %s failed: %s\n", filename, err)
+			os.Exit(1)
+		}
+	}
+	flag.Usage = usage
+	filename, output, err := run(flag.CommandLine, os.Args[1:], runLimits{})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if output == nil {
+		return
+	}
+	if err := writeOutput(filename, output); err != nil {
+		fmt.Fprintf(os.Stderr, "writing %s failed: %s\n", filename, err)
+		os.Exit(1)
+	}
+}
+
+// runLimits bound the sheets of run, the zero value draws every sheet.
+type runLimits struct {
+	drawing lineatur.Limits
+	check   func(cfg lineatur.Config, format string, dpi float64) error // rejects a page or region before drawing
+}
+
+// run parses the arguments with the flags and returns the output file and
+// the function writing it, or no function when there is nothing to write,
+// e.g. for -list-presets or -validate.
+func run(flags *flag.FlagSet, args []string, limits runLimits) (string, func(w io.Writer) error, error) {
+	var paperSize, _proportions, _zoneGaps, _slants, _margins, _safeArea, printer, _warmup, _poster, zoneDims, _centerCrossColor, _lineColor, _slantColor, preset, _zoneColors, _compare, _zoneStyles, _lineStyles, _order, _grid, _majorColor, _subLines, orient, _lineColors, _lineWidth, configFile, title, font, _baselineColor, format, dxfUnits, dxfLayer, _lineHeight, _lineSpacing, _grow, _cornell, _shade, _shadeColor, _rowTints, _marginLineColor, _header, layoutFile, _regions, batchFile, addr, _unit, author, watermark, watermarkImage, _watermarkPos, trace, traceFont, _traceColor, exemplar, _scale, filename string
+	var posterOverlap, bleed, spreadGutter, centerCross, vignette, fadeRight, loopGuides, marginLine, fontSize, holeOffset, majorWidth, zoneOpacity, nib, columnGutter, watermarkOpacity, watermarkAngle, watermarkWidth, baselineWidth, qrSize, dpi float64
+	var booklet, nup, pages, baselineLine, holes, rows, fitLines, columns, lineNumbers, workers int
+	var listPresets, interactive, distribute, qr, centerLine, vertical, cells, calibrate, cropMarks, deterministic, duplex, footer, nibLadder, grandStaff, slantsThrough, unitTicks, lineNumbersRight, endDots, hanging, baseline, mirror, staff, spread, landscape, slantsOverlay, pdfa, validate, bench, zipped bool
+	flags.StringVar(&filename, "o", "", "output file, - for stdout (default output.pdf, output.svg or output.dxf)")
+	flags.StringVar(&format, "format", "pdf", "Output format: pdf, svg, dxf or png, several comma-separated with -zip, if not given a .svg, .dxf or .png file of -o decides.")
+	flags.Float64Var(&dpi, "dpi", 300, "Resolution of the png format in dots per inch.")
+	flags.BoolVar(&zipped, "zip", false, "Bundle the output files in a zip archive named after -o.")
+	flags.StringVar(&dxfUnits, "dxf-units", "mm", "Units of the DXF drawing: mm, cm or in.")
+	flags.StringVar(&dxfLayer, "dxf-layer", "LINEATUR", "Layer of the DXF entities.")
+	flags.StringVar(&_unit, "unit", "mm", "Unit of the lengths given as arguments: mm, cm, in or pt, the defaults stay in mm.")
+	flags.StringVar(&paperSize, "ps", "A4", "Paper size of your printer. Possible values: A5, A4, Invoice, Legal, Letter, append L for landscape (e.g. A4L), or WIDTHxHEIGHT in mm (e.g. 120x180). Print without scaling.")
+	flags.BoolVar(&landscape, "landscape", false, "Turn the paper to landscape, the lines run along the long edge.")
+	flags.StringVar(&orient, "orient", "", "Orientation of the paper: portrait or landscape (default that of -ps).")
+	flags.StringVar(&preset, "preset", "", "Script or school paper preset or a user preset, overridden by -p, -s, -lh, -zone-colors, -grid and -margin-line.")
+	flags.BoolVar(&listPresets, "list-presets", false, "List the presets with the arguments they stand for.")
+	flags.StringVar(&_proportions, "p", "", "Line proportions.")
+	flags.StringVar(&_compare, "compare", "", "Blocks of labeled line proportions to compare.")
+	flags.StringVar(&_order, "order", "", "Drawing order of the layers from the bottom up, e.g. bg,shade,lines.")
+	flags.StringVar(&_zoneStyles, "zone-styles", "", "Styles of the zone boundaries.")
+	flags.StringVar(&_lineStyles, "style", "", "Styles of single lines by index, e.g. 2=dashed, or of all lines, e.g. solid:dash:solid.")
+	flags.StringVar(&_zoneColors, "zone-colors", "", "Tints of the zones of the line proportions.")
+	flags.StringVar(&_rowTints, "row-tints", "", "Background colors of the lines taking turns, e.g. 255:248:220,225:235:250.")
+	flags.StringVar(&_subLines, "sub", "", "Extra dotted lines dividing zones of the line proportions, e.g. 0=1.")
+	flags.StringVar(&_shade, "shade", "", "Zone of the line proportions shaded light gray, 0 is the top zone, or a band of zones as first-last.")
+	flags.StringVar(&_shadeColor, "shade-color", "235:235:235", "Color of the band of -shade.")
+	flags.Float64Var(&zoneOpacity, "zone-opacity", 1, "Opacity of the zone colors and the shade from 0 to 1.")
+	flags.StringVar(&_zoneGaps, "zone-gap", "", "Gaps between the zones of the line proportions.")
+	flags.StringVar(&zoneDims, "zone-dims", "", "Label the zones with their heights in the right margin.")
+	flags.BoolVar(&unitTicks, "unit-ticks", false, "Mark and number the units of the line proportions in the left margin.")
+	flags.IntVar(&lineNumbers, "line-numbers", 0, "Number every that many lines in the left margin, 1 = every line, 0 = none.")
+	flags.BoolVar(&lineNumbersRight, "line-numbers-right", false, "Put the line numbers of -line-numbers in the right margin.")
+	flags.StringVar(&_slants, "s", "", "Slanted helper lines.")
+	flags.StringVar(&_margins, "m", "5:15:15:5", "Page margins.")
+	flags.StringVar(&_safeArea, "safe", "", "Safe area of your printer, overrides -printer.")
+	flags.StringVar(&printer, "printer", "", "Printer profile providing the safe area.")
+	flags.StringVar(&_lineHeight, "lh", "10", "Line height in mm.")
+	flags.Float64Var(&nib, "nib", 0, "Width of the broad-edged nib in mm, the line proportions are nib widths and give the line height, 0 = -lh.")
+	flags.BoolVar(&nibLadder, "nib-ladder", false, "Draw a ladder of squares, one per unit of the line proportions, at the left of each line.")
+	flags.StringVar(&_lineSpacing, "ls", "5", "Line spacing in mm.")
+	flags.StringVar(&_grow, "grow", "0", "Increase of the line height in mm from line to line down the page.")
+	flags.StringVar(&_lineWidth, "lw", "0.3", "Line width in mm, or the widths of the lines from the top, e.g. 0.5:0.2:0.2:0.5.")
+	flags.BoolVar(&endDots, "end-dots", false, "End the horizontal lines in dots.")
+	flags.BoolVar(&hanging, "hanging", false, "The letters hang from the bold top line of the middle zone instead of sitting on its bottom line.")
+	flags.StringVar(&_grid, "grid", "", "Grid filling the page instead of the lines.")
+	flags.BoolVar(&duplex, "duplex", false, "Swap the left and right margins and the binder holes on every second page for double-sided printing.")
+	flags.BoolVar(&mirror, "mirror", false, "Mirror the sheet for left-handers: the slants lean the other way, binder holes and margin line are on the right.")
+	flags.IntVar(&holes, "holes", 0, "Mark 2 or 4 binder holes in the left margin to align the punch, 3 for the US 3-hole punch, 0 = none.")
+	flags.Float64Var(&holeOffset, "hole-offset", 0, "Offset in mm of the binder holes down from the page center.")
+	flags.StringVar(&_cornell, "cornell", "", "Cornell notes layout with the width of the cue column and the height of the summary box in mm, as cue:summary.")
+	flags.StringVar(&_marginLineColor, "margin-color", "220:40:40", "Color of the margin line.")
+	flags.BoolVar(&centerLine, "center-line", false, "Divide the lines in halves by a vertical line down the middle like on a steno pad.")
+	flags.Float64Var(&marginLine, "margin-line", 0, "Offset in mm of a red vertical margin line from the left page margin (the right one with -mirror), 0 = none.")
+	flags.BoolVar(&staff, "staff", false, "Music staves of five lines, -lh is the staff height and -ls the gap between the staves.")
+	flags.BoolVar(&grandStaff, "grand-staff", false, "Join the staves in pairs for piano music.")
+	flags.BoolVar(&vertical, "vertical", false, "Rule columns top down from the right to the left for vertical writing, -lh is their width and -ls the gutter.")
+	flags.BoolVar(&cells, "cells", false, "Divide the lines or columns into squares of the line height.")
+	flags.IntVar(&rows, "rows", 0, "At most this many lines or staves per page, 0 = as many as fit.")
+	flags.IntVar(&fitLines, "lines", 0, "Fit the line height so exactly this many lines fill the page between the margins, 0 = -lh.")
+	flags.BoolVar(&distribute, "distribute", false, "Spread the space left below the last line over the line spacing, with -lines the line height stays and the spacing is fitted.")
+	flags.BoolVar(&baseline, "baseline", false, "Draw the baseline bold.")
+	flags.IntVar(&baselineLine, "baseline-line", 0, "Line of the baseline counted down from the top line, 0 = the bottom line of the middle zone.")
+	flags.StringVar(&_baselineColor, "baseline-color", "", "Color of the baseline (default the color of the lines).")
+	flags.Float64Var(&baselineWidth, "baseline-lw", 0, "Width in mm of the bold baseline, 0 = twice the line width.")
+	flags.Float64Var(&majorWidth, "major-lw", 0.5, "Width in mm of the major lines of a squared grid.")
+	flags.StringVar(&_majorColor, "major-color", "", "Color of the major lines of a squared grid (default the color of the lines).")
+	flags.StringVar(&exemplar, "exemplar", "", "Text written light on the first line of every block to copy below it, or alphabet, capitals or pangram.")
+	flags.StringVar(&trace, "trace", "", "Text written into the lines from the top for tracing, \\n starts a new line.")
+	flags.StringVar(&traceFont, "trace-font", "", "TrueType file of a dotted or outline font for -trace (default -font).")
+	flags.StringVar(&_traceColor, "trace-color", "gray", "Color of the text of -trace.")
+	flags.StringVar(&title, "title", "", "Title written at the top margin of every page, the lines start below it.")
+	flags.BoolVar(&footer, "footer", false, "Write the page number and a description of the ruling at the bottom of every page.")
+	flags.BoolVar(&qr, "qr", false, "Print a QR code of the arguments making the sheet in the bottom right corner of every page.")
+	flags.Float64Var(&qrSize, "qr-size", 0, "Side of the QR code of -qr in mm, 0 = the largest up to 12 mm with its quiet zone in the bottom margin.")
+	flags.StringVar(&_header, "header", "", "Comma separated labels of the fields to fill in at the top of every page, e.g. Name,Date.")
+	flags.StringVar(&font, "font", "Helvetica", "Font of the title and the header.")
+	flags.Float64Var(&fontSize, "fontsize", 10, "Font size of the title and the header in points.")
+	flags.StringVar(&_warmup, "warmup", "", "Warm-up pattern in the first line.")
+	flags.BoolVar(&slantsThrough, "slants-through", false, "Draw the slants spaced by -s angle:@spacing through the gaps from the first to the last line.")
+	flags.BoolVar(&slantsOverlay, "slants-overlay", false, "Draw the slanted helper lines on an extra overlay page.")
+	flags.IntVar(&pages, "pages", 1, "Number of copies of the page for a pad, 0 is one page too.")
+	flags.IntVar(&nup, "nup", 0, "Number of pages per side of the paper, 2 side by side or 4 in two rows, cut apart after printing.")
+	flags.IntVar(&booklet, "booklet", 0, "Number of pages of a booklet printed two pages per side of the paper.")
+	flags.StringVar(&_poster, "poster", "", "Poster size, tiled across pages of the paper size.")
+	flags.Float64Var(&bleed, "bleed", 0, "Bleed in mm the media extends beyond the trimmed sheet for the print shop, 0 = none.")
+	flags.BoolVar(&cropMarks, "crop-marks", false, "Print the sheet on larger media with crop marks at its corners outside the bleed.")
+	flags.Float64Var(&posterOverlap, "poster-overlap", 10, "Overlap of the poster tiles in mm.")
+	flags.IntVar(&columns, "cols", 1, "Number of columns the lines are split into.")
+	flags.Float64Var(&columnGutter, "gutter", 10, "Width of the gutter between the columns in mm.")
+	flags.BoolVar(&spread, "spread", false, "Draw the lines across a left and a right page with an unruled gutter in the middle.")
+	flags.Float64Var(&spreadGutter, "spread-gutter", 20, "Width of the unruled gutter of a spread in mm.")
+	flags.Float64Var(&centerCross, "center-cross", 0, "Length in mm of a crosshair at the page center, 0 = none.")
+	flags.StringVar(&_lineColor, "color", "0:0:0", "Color of the lines.")
+	flags.StringVar(&_lineColors, "line-colors", "", "Colors of single lines by index, e.g. 2=black,1=lightblue.")
+	flags.StringVar(&_slantColor, "scolor", "", "Color of the slanted helper lines (default the color of the lines).")
+	flags.StringVar(&_centerCrossColor, "center-cross-color", "200:200:200", "Color of the center crosshair.")
+	flags.Float64Var(&loopGuides, "loop-guides", 0, "Distance in mm of faint ellipses spanning each line for loop practice, 0 = none.")
+	flags.Float64Var(&fadeRight, "fade-right", 0, "Fraction from 0 to 1 of the line width fading out toward the right, 0 = none.")
+	flags.Float64Var(&vignette, "vignette", 0, "Intensity from 0 to 1 of a vignette darkening the margins, 0 = none.")
+	flags.StringVar(&watermark, "watermark", "", "Light text behind the ruling, e.g. the name of the school.")
+	flags.StringVar(&watermarkImage, "watermark-image", "", "JPEG, PNG or GIF file drawn light behind the ruling, e.g. a logo.")
+	flags.Float64Var(&watermarkOpacity, "watermark-opacity", 0.15, "Opacity of the watermark greater than 0 up to 1 for opaque.")
+	flags.Float64Var(&watermarkAngle, "watermark-angle", 0, "Counter-clockwise rotation of the watermark in degrees.")
+	flags.StringVar(&_watermarkPos, "watermark-pos", "", "Center of the watermark in mm from the top left corner, as x:y (default the page center).")
+	flags.Float64Var(&watermarkWidth, "watermark-width", 0, "Width of the watermark in mm, 0 = two thirds of the page width.")
+	flags.BoolVar(&calibrate, "calibrate", false, "Only print a page with a 100 mm square and mm rulers to check that the printer doesn't scale.")
+	flags.StringVar(&_scale, "scale", "", "Factor correcting the printer, or the horizontal and vertical factors as x:y, e.g. 100/98 (default 1).")
+	flags.StringVar(&author, "author", "", "Author of the PDF metadata.")
+	flags.BoolVar(&deterministic, "deterministic", false, "Fix the creation date and leave the version out of the producer, the same arguments give the same PDF bytes.")
+	flags.BoolVar(&pdfa, "pdfa", false, "PDF/A-style XMP metadata and no features needing non-embedded fonts or transparency, not a PDF/A conformance claim, see README.md.")
+	flags.BoolVar(&validate, "validate", false, "Only validate the arguments and report all problems.")
+	flags.BoolVar(&bench, "bench", false, "Render a standard set of layouts in all formats and report the times and sizes.")
+	flags.StringVar(&layoutFile, "f", "", "JSON layout of pages and regions with their own configuration, see Layout below.")
+	flags.StringVar(&_regions, "regions", "", "JSON list of the regions of every page with the fields of lineatur.Config, see Layout below.")
+	flags.StringVar(&batchFile, "batch", "", "File of jobs, one line of arguments with -o per sheet, see Batch below.")
+	flags.IntVar(&workers, "workers", runtime.NumCPU(), "Number of jobs of -batch run at the same time.")
+	flags.StringVar(&addr, "serve", "", "Address to serve the sheets over HTTP on, e.g. :8080, see Serve below.")
+	flags.BoolVar(&interactive, "i", false, "Ask for the paper size, script, line height, slants, margins and output file one after another.")
+	flags.StringVar(&configFile, "config", "", "JSON file with flag names and values, the flags given on the command line win.")
+	if err := flags.Parse(args); err != nil {
+		return "", nil, err
+	}
+	if batchFile != "" {
+		jobs, err := readBatch(flags, batchFile)
+		if err == nil && workers < 1 {
+			err = errors.New("-workers must be at least 1")
+		}
+		if err != nil {
+			return "", nil, fmt.Errorf("reading %s failed: %s", batchFile, err)
+		}
+		// the other arguments are shared by the jobs
+		if err := runBatch(os.Stderr, jobs, sharedArguments(flags, "batch", "workers"), workers); err != nil {
+			return "", nil, fmt.Errorf("batch failed: %s", err)
+		}
+		return "", nil, nil
+	}
+	if addr != "" {
+		// the other arguments are the defaults of the requests
+		return "", nil, serve(flags, addr, sharedArguments(flags, "serve"))
+	}
+	if configFile != "" {
+		if err := applyConfigFile(flags, configFile); err != nil {
+			return "", nil, fmt.Errorf("reading %s failed: %s", configFile, err)
+		}
+	}
+	// the name of a user preset stays for the footer
+	presetName := preset
+	if _, ok := userPresets[preset]; ok {
+		if err := applyUserPreset(flags, preset, userPresets); err != nil {
+			return "", nil, err
+		}
+	}
+	if listPresets {
+		for _, name := range lineatur.PresetNames() {
+			fmt.Printf("%-12s %s\n", name, presetArguments(lineatur.Presets[name]))
+		}
+		for _, name := range userPresetNames() {
+			fmt.Printf("%-12s %s\n", name, strings.Join(userPresets[name], " "))
+		}
+		return "", nil, nil
+	}
+	if bench {
+		if err := runBench(os.Stdout); err != nil {
+			return "", nil, fmt.Errorf("benchmark failed: %s", err)
+		}
+		return "", nil, nil
+	}
+
+	// the lengths given as arguments are in -unit, the defaults stay in mm
+	unit, unitKnown := lengthUnits[_unit]
+	if !unitKnown {
+		unit = 1
+	}
+	given := map[string]bool{}
+	flags.Visit(func(f *flag.Flag) { given[f.Name] = true })
+	lengthUnit := func(name string) float64 {
+		if given[name] {
+			return unit
+		}
+		return 1
+	}
+	if interactive {
+		if given["unit"] {
+			return "", nil, errors.New("-i asks for the lengths in mm, leave out -unit")
+		}
+		answers := wizardAnswers{PaperSize: paperSize, Margins: _margins, Filename: filename, Script: "lateinisch"}
+		if given["ls"] {
+			answers.LineSpacing = _lineSpacing
+		}
+		if given["preset"] {
+			answers.Script = preset
+		} else if given["p"] {
+			answers.Script = _proportions
+		}
+		if given["lh"] {
+			answers.LineHeight = _lineHeight
+		}
+		if given["s"] {
+			answers.Slants = _slants
+		}
+		if answers.Filename == "" {
+			answers.Filename = "output.pdf"
+		}
+		args, err := runWizard(os.Stdin, os.Stderr, answers)
+		if err != nil {
+			return "", nil, fmt.Errorf("-i failed: %s", err)
+		}
+		// the answers replace the arguments they were asked for, the
+		// others stay
+		shared := sharedArguments(flags, "i", "config", "ps", "preset", "p", "lh", "s", "m", "o")
+		return run(flag.NewFlagSet("lineatur", flag.ContinueOnError), append(shared, args...), limits)
+	}
+	for name, length := range map[string]*float64{
+		"nib": &nib, "hole-offset": &holeOffset, "margin-line": &marginLine, "major-lw": &majorWidth, "poster-overlap": &posterOverlap, "bleed": &bleed,
+		"gutter": &columnGutter, "spread-gutter": &spreadGutter, "center-cross": &centerCross, "loop-guides": &loopGuides,
+		"watermark-width": &watermarkWidth, "baseline-lw": &baselineWidth, "qr-size": &qrSize,
+	} {
+		*length = inMM(*length, lengthUnit(name))
+	}
+
+	cfg := lineatur.Config{
+		ZoneDims:         zoneDims,
+		UnitTicks:        unitTicks,
+		LineNumbers:      lineNumbers,
+		LineNumbersRight: lineNumbersRight,
+		EndDots:          endDots,
+		Hanging:          hanging,
+		SlantsOverlay:    slantsOverlay,
+		SlantsThrough:    slantsThrough,
+		PosterOverlap:    posterOverlap,
+		Bleed:            bleed,
+		CropMarks:        cropMarks,
+		Calibrate:        calibrate,
+		Spread:           spread,
+		SpreadGutter:     spreadGutter,
+		CenterCross:      centerCross,
+		Vignette:         vignette,
+		Watermark:        watermark,
+		WatermarkImage:   watermarkImage,
+		WatermarkOpacity: watermarkOpacity,
+		WatermarkAngle:   watermarkAngle,
+		WatermarkWidth:   watermarkWidth,
+		NibLadder:        nibLadder,
+		ZoneOpacity:      zoneOpacity,
+		FadeRight:        fadeRight,
+		LoopGuides:       loopGuides,
+		MarginLine:       marginLine,
+		CenterLine:       centerLine,
+		Vertical:         vertical,
+		Cells:            cells,
+		Mirror:           mirror,
+		Staff:            staff,
+		GrandStaff:       grandStaff,
+		Rows:             rows,
+		Lines:            fitLines,
+		Distribute:       distribute,
+		Holes:            holes,
+		Duplex:           duplex,
+		Columns:          columns,
+		ColumnGutter:     columnGutter,
+		MajorWidth:       majorWidth,
+		HoleOffset:       holeOffset,
+		Exemplar:         exemplar,
+		TraceText:        strings.ReplaceAll(trace, `\n`, "\n"),
+		TraceFont:        traceFont,
+		Title:            title,
+		Footer:           footer,
+		QRSize:           qrSize,
+		Preset:           presetName,
+		Font:             font,
+		TitleSize:        fontSize,
+		Baseline:         baseline,
+		BaselineLine:     baselineLine,
+		BaselineWidth:    baselineWidth,
+		Booklet:          booklet,
+		Nup:              nup,
+		Pages:            pages,
+		PDFA:             pdfa,
+		Author:           author,
+		Deterministic:    deterministic,
+	}
+	if qr {
+		// the config file and the user presets are applied to the flags,
+		// their arguments make the same sheet without them
+		cfg.QR = commandLine(append([]string{"lineatur"}, sharedArguments(flags, "o", "config")...))
+	}
+	// collect all problems instead of stopping at the first one
+	problems := []error{}
+	var err error
+	if !unitKnown {
+		problems = append(problems, fmt.Errorf("wrong arguments for -unit: %s (mm, cm, in or pt)", _unit))
+	}
+	if cfg.PaperSize, err = parseSize(paperSize, lengthUnit("ps")); err != nil {
+		problems = append(problems, fmt.Errorf("wrong arguments for -ps: %s", err))
+	}
+	switch orient {
+	case "":
+	case "landscape":
+		landscape = true
+	case "portrait":
+		if landscape {
+			problems = append(problems, errors.New("-orient portrait contradicts -landscape"))
+		}
+	default:
+		problems = append(problems, fmt.Errorf("wrong arguments for -orient: %s (portrait or landscape)", orient))
+	}
+	// the margins stay top, right, bottom and left of the turned page
+	turned := (landscape && cfg.PaperSize.Width < cfg.PaperSize.Height) || (orient == "portrait" && cfg.PaperSize.Width > cfg.PaperSize.Height)
+	if turned {
+		cfg.PaperSize = lineatur.PaperSize{Width: cfg.PaperSize.Height, Height: cfg.PaperSize.Width}
+	}
+	if cfg.Proportions, err = parseMultiFloat(_proportions); err != nil {
+		problems = append(problems, fmt.Errorf("wrong arguments for -p: %s", err))
+	}
+	// the dimensions may be given as expressions of the paper sizes
+	vars := dimensionVariables(cfg.PaperSize, unit)
+	if cfg.LineHeight, err = evalDimension(_lineHeight, vars, lengthUnit("lh")); err != nil {
+		problems = append(problems, fmt.Errorf("wrong arguments for -lh: %s", err))
+	}
+	if cfg.LineSpacing, err = evalDimension(_lineSpacing, vars, lengthUnit("ls")); err != nil {
+		problems = append(problems, fmt.Errorf("wrong arguments for -ls: %s", err))
+	}
+	if cfg.Grow, err = evalDimension(_grow, vars, lengthUnit("grow")); err != nil {
+		problems = append(problems, fmt.Errorf("wrong arguments for -grow: %s", err))
+	}
+	if cfg.ZoneGaps, err = parseDimensions(_zoneGaps, vars, lengthUnit("zone-gap")); err != nil {
+		problems = append(problems, fmt.Errorf("wrong arguments for -zone-gap: %s", err))
+	}
+	if angle, spacing, ok := strings.Cut(_slants, ":@"); ok {
+		// a slant every spacing mm instead of a number per line
+		angles, err := parseMultiUint64(angle)
+		spacings, err2 := parseMultiFloat(spacing)
+		if err != nil || err2 != nil || len(angles) != 1 || len(spacings) != 1 || spacings[0] == 0 {
+			problems = append(problems, fmt.Errorf("wrong arguments for -s: %s", _slants))
+		} else {
+			cfg.Slants, cfg.SlantSpacing = []float64{angles[0], 0}, inMM(spacings[0], lengthUnit("s"))
+		}
+	} else if cfg.Slants, err = parseMultiUint64(_slants); err != nil {
+		problems = append(problems, fmt.Errorf("wrong arguments for -s: %s", _slants))
+	}
+	if _grid != "" {
+		pattern, spacing, _ := strings.Cut(_grid, ":")
+		cfg.Grid = pattern
+		spacingValues, err := parseMultiFloat(spacing)
+		if err != nil || len(spacingValues) == 0 || len(spacingValues) > 2 || (len(spacingValues) == 2 && pattern != "dots" && pattern != "squares") {
+			problems = append(problems, fmt.Errorf("wrong arguments for -grid: %s", _grid))
+		} else {
+			cfg.GridSpacing = inMM(spacingValues[0], lengthUnit("grid"))
+			if len(spacingValues) == 2 && pattern == "dots" {
+				cfg.DotSize = inMM(spacingValues[1], lengthUnit("grid"))
+			}
+			if len(spacingValues) == 2 && pattern == "squares" {
+				if cfg.GridMajor = int(spacingValues[1]); float64(cfg.GridMajor) != spacingValues[1] {
+					problems = append(problems, fmt.Errorf("wrong arguments for -grid: %s (whole cells between the major lines)", _grid))
+				}
+			}
+		}
+	}
+	if _warmup != "" {
+		pattern, values, _ := strings.Cut(_warmup, ":")
+		cfg.Warmup = pattern
+		warmupValues, err := parseMultiFloat(values)
+		if err != nil || len(warmupValues) != 2 {
+			problems = append(problems, fmt.Errorf("wrong arguments for -warmup: %s", _warmup))
+		} else {
+			cfg.WarmupAmplitude, cfg.WarmupLength = inMM(warmupValues[0], lengthUnit("warmup")), inMM(warmupValues[1], lengthUnit("warmup"))
+		}
+	}
+	margins, err := parseDimensions(_margins, vars, lengthUnit("m"))
+	if err != nil {
+		problems = append(problems, fmt.Errorf("wrong arguments for -m: %s", err))
+	}
+	for _, m := range margins {
+		// the safe area would widen a negative margin to its inset
+		if m < 0 {
+			problems = append(problems, fmt.Errorf("wrong arguments for -m: margins %s must not be negative", _margins))
+			break
+		}
+	}
+	safeArea := lineatur.SafeArea{}
+	if printer != "" {
+		if profile, ok := lineatur.PrinterProfiles[printer]; ok {
+			safeArea = profile
+		} else {
+			problems = append(problems, fmt.Errorf("printer profile \"%s\" is unknown, possible values: %s", printer, strings.Join(lineatur.PrinterNames(), ", ")))
+		}
+	}
+	if _safeArea != "" {
+		insets, err := parseDimensions(_safeArea, vars, lengthUnit("safe"))
+		if err != nil {
+			problems = append(problems, fmt.Errorf("wrong arguments for -safe: %s", err))
+		} else if len(insets) != 4 {
+			problems = append(problems, fmt.Errorf("wrong number of arguments for -safe: %s", _safeArea))
+		} else {
+			safeArea = lineatur.SafeArea{Top: insets[0], Right: insets[1], Bottom: insets[2], Left: insets[3]}
+			if err := safeArea.Validate(); err != nil {
+				problems = append(problems, fmt.Errorf("wrong arguments for -safe: %s", err))
+			}
+		}
+	}
+	cfg.Margins = lineatur.ApplySafeArea(margins, safeArea)
+	if _header != "" {
+		cfg.Header = strings.Split(_header, ",")
+	}
+	if _cornell != "" {
+		if cfg.Cornell, err = parseDimensions(_cornell, vars, lengthUnit("cornell")); err != nil {
+			problems = append(problems, fmt.Errorf("wrong arguments for -cornell: %s", err))
+		}
+	}
+	if _scale != "" {
+		// the factors have no unit
+		if cfg.Scale, err = parseDimensions(_scale, vars, 1); err != nil {
+			problems = append(problems, fmt.Errorf("wrong arguments for -scale: %s", err))
+		} else if len(cfg.Scale) == 1 {
+			cfg.Scale = []float64{cfg.Scale[0], cfg.Scale[0]}
+		}
+	}
+	if _watermarkPos != "" {
+		if cfg.WatermarkCenter, err = parseDimensions(_watermarkPos, vars, lengthUnit("watermark-pos")); err != nil {
+			problems = append(problems, fmt.Errorf("wrong arguments for -watermark-pos: %s", err))
+		}
+	}
+	if watermarkOpacity == 0 {
+		// the library draws an opacity of 0 opaque
+		problems = append(problems, errors.New("wrong arguments for -watermark-opacity: 0, the opacity must be greater than 0"))
+	}
+	if text, ok := lineatur.Exemplars[exemplar]; ok {
+		cfg.Exemplar = text
+	}
+	if cfg.TraceColor, err = parseColor(_traceColor); err != nil {
+		problems = append(problems, fmt.Errorf("wrong arguments for -trace-color: %s", _traceColor))
+	}
+	if traceFont != "" {
+		if _, err := os.Stat(traceFont); err != nil {
+			problems = append(problems, fmt.Errorf("wrong arguments for -trace-font: %s", err))
+		}
+	}
+	if watermarkImage != "" {
+		if _, err := os.Stat(watermarkImage); err != nil {
+			problems = append(problems, fmt.Errorf("wrong arguments for -watermark-image: %s", err))
+		}
+	}
+	if _poster != "" {
+		if cfg.Poster, err = parseSize(_poster, lengthUnit("poster")); err != nil {
+			problems = append(problems, fmt.Errorf("wrong arguments for -poster: %s", _poster))
+		}
+	}
+	if cfg.LineColor, err = parseColor(_lineColor); err != nil {
+		problems = append(problems, fmt.Errorf("wrong arguments for -color: %s", _lineColor))
+	}
+	cfg.SlantColor = cfg.LineColor
+	if _slantColor != "" {
+		if cfg.SlantColor, err = parseColor(_slantColor); err != nil {
+			problems = append(problems, fmt.Errorf("wrong arguments for -scolor: %s", _slantColor))
+		}
+	}
+	cfg.MajorColor = cfg.LineColor
+	if _majorColor != "" {
+		if cfg.MajorColor, err = parseColor(_majorColor); err != nil {
+			problems = append(problems, fmt.Errorf("wrong arguments for -major-color: %s", _majorColor))
+		}
+	}
+	if cfg.MarginLineColor, err = parseColor(_marginLineColor); err != nil {
+		problems = append(problems, fmt.Errorf("wrong arguments for -margin-color: %s", _marginLineColor))
+	}
+	cfg.BaselineColor = cfg.LineColor
+	if _baselineColor != "" {
+		if cfg.BaselineColor, err = parseColor(_baselineColor); err != nil {
+			problems = append(problems, fmt.Errorf("wrong arguments for -baseline-color: %s", _baselineColor))
+		}
+	}
+	if cfg.CenterCrossColor, err = parseColor(_centerCrossColor); err != nil {
+		problems = append(problems, fmt.Errorf("wrong arguments for -center-cross-color: %s", _centerCrossColor))
+	}
+	if _zoneStyles != "" {
+		for _, style := range strings.Split(_zoneStyles, ":") {
+			cfg.ZoneStyles = append(cfg.ZoneStyles, styleName(style))
+		}
+	}
+	if _order != "" {
+		cfg.Order = strings.Split(_order, ",")
+	}
+	if cfg.Compare, err = parseCompare(_compare); err != nil {
+		problems = append(problems, fmt.Errorf("wrong arguments for -compare: %s", _compare))
+	}
+	if cfg.ZoneColors, err = parseColors(_zoneColors); err != nil {
+		problems = append(problems, fmt.Errorf("wrong arguments for -zone-colors: %s", _zoneColors))
+	}
+	if cfg.RowTints, err = parseColors(_rowTints); err != nil {
+		problems = append(problems, fmt.Errorf("wrong arguments for -row-tints: %s", _rowTints))
+	}
+	if preset != "" {
+		if p, ok := lineatur.Presets[preset]; ok {
+			// explicitly given arguments win over the preset
+			if !given["p"] {
+				cfg.Proportions = p.Proportions
+			}
+			if !given["s"] {
+				cfg.Slants = p.Slants
+			}
+			if !given["lh"] && p.LineHeight > 0 {
+				cfg.LineHeight = p.LineHeight
+			}
+			if !given["ls"] && p.LineSpacing > 0 {
+				cfg.LineSpacing = p.LineSpacing
+			}
+			if !given["zone-colors"] {
+				cfg.ZoneColors = p.ZoneColors
+			}
+			if !given["grid"] {
+				cfg.Grid, cfg.GridSpacing = p.Grid, p.GridSpacing
+			}
+			if !given["margin-line"] {
+				cfg.MarginLine = p.MarginLine
+			}
+			if !given["row-tints"] {
+				cfg.RowTints = p.RowTints
+			}
+			if !given["baseline"] {
+				cfg.Baseline = p.Baseline
+			}
+			if !given["center-line"] {
+				cfg.CenterLine = p.CenterLine
+			}
+		} else {
+			names := append(lineatur.PresetNames(), userPresetNames()...)
+			problems = append(problems, fmt.Errorf("preset \"%s\" is unknown, possible values: %s", preset, strings.Join(names, ", ")))
+		}
+	}
+	// the line indexes refer to the proportions of the preset too
+	if nib != 0 {
+		if nib < 0 {
+			problems = append(problems, errors.New("nib width must be greater than 0"))
+		} else if given["lh"] {
+			problems = append(problems, errors.New("-nib gives the line height, it can't be combined with -lh"))
+		} else if len(cfg.Proportions) == 0 {
+			problems = append(problems, errors.New("-nib needs line proportions in nib widths"))
+		} else {
+			// the zone gaps aren't measured in nib widths
+			cfg.LineHeight = 0
+			for _, p := range cfg.Proportions {
+				cfg.LineHeight += nib * p
+			}
+			for _, gap := range cfg.ZoneGaps {
+				cfg.LineHeight += gap
+			}
+		}
+	}
+	if fitLines != 0 && !distribute && (given["lh"] || nib != 0) {
+		problems = append(problems, errors.New("-lines gives the line height, it can't be combined with -lh or -nib, add -distribute to fit the line spacing instead"))
+	}
+	if _lineStyles != "" {
+		if cfg.ZoneStyles, err = parseLineStyles(_lineStyles, cfg.ZoneStyles, len(cfg.Proportions)+1); err != nil {
+			problems = append(problems, fmt.Errorf("wrong arguments for -style: %s", err))
+		}
+	}
+	if widths, err := parseMultiFloat(_lineWidth); err != nil || len(widths) == 0 {
+		problems = append(problems, fmt.Errorf("wrong arguments for -lw: %s", _lineWidth))
+	} else if len(widths) == 1 {
+		cfg.LineWidth = inMM(widths[0], lengthUnit("lw"))
+	} else {
+		for i := range widths {
+			widths[i] = inMM(widths[i], lengthUnit("lw"))
+		}
+		// the borders, slants and guides get the thinnest width
+		cfg.LineWidths, cfg.LineWidth = widths, widths[0]
+		for _, w := range widths {
+			cfg.LineWidth = math.Min(cfg.LineWidth, w)
+		}
+	}
+	if _subLines != "" && len(cfg.Proportions) == 0 {
+		problems = append(problems, errors.New("-sub needs line proportions (-p or a preset)"))
+	} else if _subLines != "" {
+		if cfg.SubLines, err = parseSubLines(_subLines, len(cfg.Proportions)); err != nil {
+			problems = append(problems, fmt.Errorf("wrong arguments for -sub: %s", err))
+		}
+	}
+	if _lineColors != "" {
+		if cfg.LineColors, err = parseLineColors(_lineColors, cfg.LineColor, len(cfg.Proportions)+1); err != nil {
+			problems = append(problems, fmt.Errorf("wrong arguments for -line-colors: %s", err))
+		}
+	}
+	if _shade != "" {
+		first, last, err := parseZoneBand(_shade, len(cfg.Proportions))
+		if err != nil {
+			problems = append(problems, fmt.Errorf("wrong arguments for -shade: %s", err))
+		} else if _zoneColors != "" {
+			problems = append(problems, errors.New("-shade is a shorthand for -zone-colors, they can't be combined"))
+		} else if shadeColor, err := parseColor(_shadeColor); err != nil {
+			problems = append(problems, fmt.Errorf("wrong arguments for -shade-color: %s", _shadeColor))
+		} else {
+			cfg.ZoneColors = make([]lineatur.Color, last+1)
+			for i := range cfg.ZoneColors {
+				cfg.ZoneColors[i] = lineatur.White
+				if i >= first {
+					cfg.ZoneColors[i] = shadeColor
+				}
+			}
+		}
+	}
+	if ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(filename)), "."); !given["format"] && !zipped && (ext == "svg" || ext == "dxf" || ext == "png") {
+		// without -format the extension of the output file decides
+		format = ext
+	}
+	formats := strings.Split(format, ",")
+	if len(formats) > 1 && !zipped {
+		problems = append(problems, fmt.Errorf("several output formats %s need -zip", format))
+	}
+	for _, format := range formats {
+		switch format {
+		case "pdf":
+		case "svg", "dxf", "png":
+			if cfg.PDFA {
+				problems = append(problems, errors.New("-pdfa needs the pdf format"))
+			}
+			if _, ok := lineatur.DXFUnits[dxfUnits]; !ok && format == "dxf" {
+				problems = append(problems, fmt.Errorf("unknown DXF units %s", dxfUnits))
+			}
+			if dpi <= 0 && format == "png" {
+				problems = append(problems, errors.New("resolution of the png format must be greater than 0"))
+			}
+		default:
+			problems = append(problems, fmt.Errorf("unknown output format %s", format))
+		}
+	}
+	cfg.Limits = limits.drawing
+	problems = append(problems, cfg.Validate())
+	// the pages of the layout start from the configuration of the flags
+	var layout []lineatur.LayoutPage
+	if _regions != "" && layoutFile != "" {
+		problems = append(problems, errors.New("-regions are the regions of every page, a layout file has its own"))
+	} else if _regions != "" {
+		// the regions make a layout of one page, repeated by -pages
+		data := fmt.Sprintf(`{"Pages": [{"Regions": %s}]}`, _regions)
+		if layout, err = lineatur.ParseLayout([]byte(data), cfg); err != nil {
+			problems = append(problems, fmt.Errorf("wrong arguments for -regions: %s", err))
+		}
+	}
+	if layoutFile != "" {
+		if data, err := os.ReadFile(layoutFile); err != nil {
+			problems = append(problems, fmt.Errorf("reading %s failed: %s", layoutFile, err))
+		} else if layout, err = lineatur.ParseLayout(data, cfg); err != nil {
+			problems = append(problems, fmt.Errorf("wrong layout %s: %s", layoutFile, err))
+		}
+	}
+	if limits.check != nil && layout == nil {
+		problems = append(problems, limits.check(cfg, format, dpi))
+	}
+	for _, page := range layout {
+		if limits.check == nil {
+			break
+		}
+		problems = append(problems, limits.check(page.Config, format, dpi))
+		for _, region := range page.Regions {
+			problems = append(problems, limits.check(region.Config, format, dpi))
+		}
+	}
+	render := func(format string) func(w io.Writer) error {
+		if layout != nil {
+			return lineatur.RenderLayout(layout, format, dxfUnits, dxfLayer, dpi)
+		}
+		return lineatur.Render(cfg, format, dxfUnits, dxfLayer, dpi)
+	}
+	if err := errors.Join(problems...); err != nil {
+		return "", nil, err
+	}
+	if validate {
+		fmt.Fprintln(os.Stderr, "no problems found")
+		return "", nil, nil
+	}
+
+	var output func(w io.Writer) error
+
+	if zipped {
+		// the files in the archive are named after it
+		base := "output"
+		if filename != "" && filename != "-" {
+			base = strings.TrimSuffix(filename, filepath.Ext(filename))
+		}
+		if filename != "-" {
+			filename = base + ".zip"
+		}
+		entries := []zipEntry{}
+		for _, format := range formats {
+			entries = append(entries, zipEntry{filepath.Base(base) + "." + format, render(format)})
+		}
+		output = func(w io.Writer) error {
+			return writeZip(w, entries)
+		}
+	} else {
+		output = render(format)
+	}
+	if filename == "" {
+		filename = "output." + format
+	}
+	return filename, output, nil
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/maptry/lineatur"
+)
+
+// Limits of the sheets of -serve.
+const (
+	serveMaxPages     = 100
+	serveMaxDPI       = 600
+	serveMaxSize      = 2000     // mm of the paper or poster
+	serveMaxPixels    = 50e6     // of the png pages, drawn in memory and again joined into one image
+	serveMaxBytes     = 50 << 20 // of the output
+	serveMaxQuery     = 4096
+	serveWriteTimeout = 30 * time.Second
+	serveDrawTimeout  = 20 * time.Second // of the drawing, within the write timeout
+	serveMinSpacing   = 1.0              // mm of the grids, slants, loop guides and warm-up waves
+	serveMaxSlants    = 200              // per line
+	serveMaxSubLines  = 20               // per zone
+	serveMaxElements  = 1000000          // lines, shapes and texts of a sheet
+)
+
+// serveFormats are the content types of the formats of -serve.
+var serveFormats = map[string]string{
+	"pdf": "application/pdf",
+	"svg": "image/svg+xml",
+	"dxf": "application/dxf",
+	"png": "image/png",
+}
+
+// serveForbidden are the flags a request can't set, they read or write files
+// of the server or don't make a sheet. The files of the configuration, e.g.
+// those of the regions, are refused by serveCheck.
+var serveForbidden = map[string]bool{
+	"o": true, "format": true, "zip": true, "config": true, "f": true, "batch": true, "workers": true,
+	"serve": true, "bench": true, "list-presets": true, "validate": true, "i": true, "watermark-image": true, "trace-font": true,
+}
+
+// errTooLarge is returned when the output exceeds serveMaxBytes.
+var errTooLarge = errors.New("the sheet is too large")
+
+// limitedBuffer is a buffer refusing to grow beyond serveMaxBytes.
+type limitedBuffer struct {
+	bytes.Buffer
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.Len()+len(p) > serveMaxBytes {
+		return 0, errTooLarge
+	}
+	return b.Buffer.Write(p)
+}
+
+// requestArguments returns the arguments of the query of a request, the
+// parameters are the names of the flags, e.g. ?preset=copperplate&lh=8&ps=Letter,
+// an empty value sets a boolean flag.
+func requestArguments(flags *flag.FlagSet, query map[string][]string) ([]string, error) {
+	names := []string{}
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	args := []string{}
+	for _, name := range names {
+		if serveForbidden[name] || flags.Lookup(name) == nil {
+			return nil, fmt.Errorf("unknown parameter %s", name)
+		}
+		for _, value := range query[name] {
+			if value == "" {
+				args = append(args, "-"+name)
+			} else {
+				args = append(args, "-"+name+"="+value)
+			}
+		}
+	}
+	if _, err := parseArguments(flags, args); err != nil {
+		return nil, err
+	}
+	return args, nil
+}
+
+// serveCheck rejects a page or region of a request reading files of the
+// server, too large for the memory or drawing too many elements, the element
+// limit stops the drawings it doesn't foresee. The sizes are those of the
+// configuration in mm, whatever -unit or preset they come from.
+func serveCheck(cfg lineatur.Config, format string, dpi float64) error {
+	problems := []error{}
+	if cfg.TraceFont != "" || cfg.WatermarkImage != "" {
+		problems = append(problems, errors.New("no font or image files of the server"))
+	}
+	if cfg.Pages > serveMaxPages || cfg.Booklet > serveMaxPages {
+		problems = append(problems, fmt.Errorf("at most %d pages", serveMaxPages))
+	}
+	if dpi > serveMaxDPI {
+		problems = append(problems, fmt.Errorf("at most %d dpi", serveMaxDPI))
+	}
+	for _, size := range []lineatur.PaperSize{cfg.PaperSize, cfg.Poster} {
+		if size.Width > serveMaxSize || size.Height > serveMaxSize {
+			problems = append(problems, fmt.Errorf("at most %d mm for the paper and poster sizes", serveMaxSize))
+			break
+		}
+	}
+	if len(problems) == 0 && format == "png" && cfg.Pixels(dpi) > serveMaxPixels {
+		problems = append(problems, fmt.Errorf("at most %g million pixels of the png pages", serveMaxPixels/1e6))
+	}
+	if cfg.Grid != "" && cfg.GridSpacing < serveMinSpacing {
+		problems = append(problems, fmt.Errorf("a grid spacing of at least %g mm", serveMinSpacing))
+	}
+	if cfg.SlantSpacing > 0 && cfg.SlantSpacing < serveMinSpacing {
+		problems = append(problems, fmt.Errorf("at least %g mm between the slants", serveMinSpacing))
+	} else if cfg.SlantSpacing == 0 && len(cfg.Slants) == 2 && cfg.Slants[1] > serveMaxSlants {
+		problems = append(problems, fmt.Errorf("at most %d slants per line", serveMaxSlants))
+	}
+	if cfg.LoopGuides > 0 && cfg.LoopGuides < serveMinSpacing {
+		problems = append(problems, fmt.Errorf("at least %g mm between the loop guides", serveMinSpacing))
+	}
+	if cfg.Warmup != "" && cfg.WarmupLength < serveMinSpacing {
+		problems = append(problems, fmt.Errorf("a warm-up wavelength of at least %g mm", serveMinSpacing))
+	}
+	for _, n := range cfg.SubLines {
+		if n > serveMaxSubLines {
+			problems = append(problems, fmt.Errorf("at most %d sub-lines per zone", serveMaxSubLines))
+			break
+		}
+	}
+	return errors.Join(problems...)
+}
+
+// serveSheet returns the function writing the sheet of the arguments of a
+// request within the limits, every request parses its own flags.
+func serveSheet(ctx context.Context, args []string) (func(w io.Writer) error, error) {
+	flags := flag.NewFlagSet("lineatur", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	limits := runLimits{drawing: lineatur.Limits{Context: ctx, Elements: serveMaxElements}, check: serveCheck}
+	_, output, err := run(flags, args, limits)
+	if err == nil && output == nil {
+		err = errors.New("no sheet")
+	}
+	return output, err
+}
+
+// serve answers requests for /lineatur.pdf, .svg, .dxf and .png with the
+// sheet of the query on top of the shared arguments. At most one sheet per
+// CPU is drawn at a time.
+func serve(flags *flag.FlagSet, addr string, shared []string) error {
+	slots := make(chan struct{}, runtime.NumCPU())
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "only GET requests", http.StatusMethodNotAllowed)
+			return
+		}
+		if len(r.URL.RawQuery) > serveMaxQuery {
+			http.Error(w, "the query is too long", http.StatusRequestURITooLong)
+			return
+		}
+		args, err := requestArguments(flags, r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		format := strings.TrimPrefix(path.Ext(r.URL.Path), ".")
+		args = append(append(append([]string{}, shared...), args...), "-format="+format)
+		select {
+		case slots <- struct{}{}:
+			defer func() { <-slots }()
+		case <-r.Context().Done():
+			return
+		}
+		// the drawing stops at the deadline or when the client is gone
+		ctx, cancel := context.WithTimeout(r.Context(), serveDrawTimeout)
+		defer cancel()
+		output, err := serveSheet(ctx, args)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		buffer := &limitedBuffer{}
+		if err := output(buffer); errors.Is(err, errTooLarge) || errors.Is(err, lineatur.ErrTooManyElements) {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		} else if errors.Is(err, context.DeadlineExceeded) {
+			http.Error(w, "drawing the sheet takes too long", http.StatusServiceUnavailable)
+			return
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", serveFormats[format])
+		w.Header().Set("Content-Length", strconv.Itoa(buffer.Len()))
+		w.Write(buffer.Bytes())
+	}
+	mux := http.NewServeMux()
+	for format := range serveFormats {
+		mux.HandleFunc("/lineatur."+format, handler)
+	}
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+		WriteTimeout:      serveWriteTimeout,
+		MaxHeaderBytes:    2 * serveMaxQuery,
+	}
+	return server.ListenAndServe()
+}
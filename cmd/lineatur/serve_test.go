@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"io"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/maptry/lineatur"
+)
+
+// lineaturFlags returns the flags of the command line, without parsing any.
+func lineaturFlags() *flag.FlagSet {
+	flags := flag.NewFlagSet("lineatur", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	run(flags, []string{"-h"}, runLimits{})
+	return flags
+}
+
+func TestRequestArguments(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []string
+		err   string
+	}{
+		{query: "preset=copperplate&lh=8", want: []string{"-lh=8", "-preset=copperplate"}},
+		{query: "footer&pages=2", want: []string{"-footer", "-pages=2"}},
+		{query: "lh=8&lh=9", want: []string{"-lh=8", "-lh=9"}},
+		{query: "pages=0x2710", want: []string{"-pages=0x2710"}},
+		{query: "o=/tmp/sheet.pdf", err: "unknown parameter o"},
+		{query: "config=/etc/passwd", err: "unknown parameter config"},
+		{query: "f=layout.json", err: "unknown parameter f"},
+		{query: "trace-font=/etc/hostname", err: "unknown parameter trace-font"},
+		{query: "watermark-image=/etc/hostname", err: "unknown parameter watermark-image"},
+		{query: "serve=:8081", err: "unknown parameter serve"},
+		{query: "nosuch=1", err: "unknown parameter nosuch"},
+	}
+	flags := lineaturFlags()
+	for _, test := range tests {
+		query, err := url.ParseQuery(test.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := requestArguments(flags, query)
+		switch {
+		case test.err == "" && err != nil:
+			t.Errorf("%s: %v", test.query, err)
+		case test.err == "" && !reflect.DeepEqual(got, test.want):
+			t.Errorf("%s: got %q, want %q", test.query, got, test.want)
+		case test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)):
+			t.Errorf("%s: got %q, %v, want error %q", test.query, got, err, test.err)
+		}
+	}
+}
+
+func TestServeCheck(t *testing.T) {
+	base := lineatur.Config{PaperSize: lineatur.PaperSizes["A4"]}
+	with := func(change func(cfg *lineatur.Config)) lineatur.Config {
+		cfg := base
+		change(&cfg)
+		return cfg
+	}
+	tests := []struct {
+		name   string
+		cfg    lineatur.Config
+		format string
+		dpi    float64
+		err    string
+	}{
+		{name: "A4", cfg: base, format: "png", dpi: 300},
+		{name: "trace font", cfg: with(func(cfg *lineatur.Config) { cfg.TraceFont = "/dev/zero" }), format: "pdf", err: "no font or image files"},
+		{name: "watermark image", cfg: with(func(cfg *lineatur.Config) { cfg.WatermarkImage = "/etc/hostname" }), format: "pdf", err: "no font or image files"},
+		{name: "pages", cfg: with(func(cfg *lineatur.Config) { cfg.Pages = 100 }), format: "pdf"},
+		{name: "too many pages", cfg: with(func(cfg *lineatur.Config) { cfg.Pages = 10000 }), format: "pdf", err: "at most 100 pages"},
+		{name: "booklet", cfg: with(func(cfg *lineatur.Config) { cfg.Booklet = 101 }), format: "pdf", err: "at most 100 pages"},
+		{name: "dpi", cfg: base, format: "png", dpi: 601, err: "at most 600 dpi"},
+		{name: "paper", cfg: with(func(cfg *lineatur.Config) { cfg.PaperSize = lineatur.PaperSize{Width: 2001, Height: 100} }), format: "pdf", err: "at most 2000 mm"},
+		{name: "poster", cfg: with(func(cfg *lineatur.Config) { cfg.Poster = lineatur.PaperSize{Width: 1000, Height: 3000} }), format: "pdf", err: "at most 2000 mm"},
+		{name: "large png", cfg: with(func(cfg *lineatur.Config) { cfg.PaperSize = lineatur.PaperSize{Width: 2000, Height: 2000} }), format: "png", dpi: 600, err: "million pixels"},
+		{name: "large pdf", cfg: with(func(cfg *lineatur.Config) { cfg.PaperSize = lineatur.PaperSize{Width: 2000, Height: 2000} }), format: "pdf", dpi: 600},
+		{name: "png pages", cfg: with(func(cfg *lineatur.Config) { cfg.Pages = 10 }), format: "png", dpi: 300, err: "million pixels"},
+		{name: "png poster", cfg: with(func(cfg *lineatur.Config) {
+			cfg.Poster, cfg.PosterOverlap = lineatur.PaperSize{Width: 1189, Height: 841}, 10
+		}), format: "png", dpi: 300, err: "million pixels"},
+		{name: "grid", cfg: with(func(cfg *lineatur.Config) { cfg.Grid, cfg.GridSpacing = "dots", 0.5 }), format: "pdf", err: "grid spacing"},
+		{name: "slants", cfg: with(func(cfg *lineatur.Config) { cfg.Slants = []float64{55, 201} }), format: "pdf", err: "at most 200 slants"},
+		{name: "slant spacing", cfg: with(func(cfg *lineatur.Config) { cfg.Slants, cfg.SlantSpacing = []float64{55, 10}, 0.5 }), format: "pdf", err: "between the slants"},
+		{name: "loop guides", cfg: with(func(cfg *lineatur.Config) { cfg.LoopGuides = 0.1 }), format: "pdf", err: "loop guides"},
+		{name: "warm-up", cfg: with(func(cfg *lineatur.Config) { cfg.Warmup, cfg.WarmupLength = "wave", 0.1 }), format: "pdf", err: "wavelength"},
+		{name: "sub-lines", cfg: with(func(cfg *lineatur.Config) { cfg.SubLines = []int{1, 21, 1} }), format: "pdf", err: "sub-lines"},
+	}
+	for _, test := range tests {
+		err := serveCheck(test.cfg, test.format, test.dpi)
+		switch {
+		case test.err == "" && err != nil:
+			t.Errorf("%s: %v", test.name, err)
+		case test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)):
+			t.Errorf("%s: got %v, want error %q", test.name, err, test.err)
+		}
+	}
+}
+
+func TestServeSheet(t *testing.T) {
+	tests := []struct {
+		query string
+		err   string
+	}{
+		{query: "preset=copperplate&lh=8&ps=Letter&format=svg"},
+		{query: "pages=0x2710&format=svg", err: "at most 100 pages"},
+		{query: "pages=x&format=svg", err: "invalid value"},
+		{query: "booklet=0x100&format=svg", err: "at most 100 pages"},
+		{query: "ps=2000x2000&dpi=600&format=png", err: "million pixels"},
+		{query: "pages=100&dpi=300&format=png", err: "million pixels"},
+		{query: "unit=cm&ps=21x29.7&format=svg"},
+		{query: "unit=in&ps=80x10&format=svg", err: "at most 2000 mm"},
+		{query: "unit=in&poster=40x100&format=svg", err: "at most 2000 mm"},
+		{query: "unit=in&dpi=601&format=png", err: "at most 600 dpi"},
+		{query: `regions=[{"Top":10,"Height":100,"TraceText":"x","TraceFont":"/etc/hostname"}]&format=svg`, err: "no font or image files"},
+		{query: `regions=[{"Top":10,"Height":100,"WatermarkImage":"/etc/hostname"}]&format=svg`, err: "no font or image files"},
+		{query: `regions=[{"Top":10,"Height":100,"Grid":"dots","GridSpacing":0.05}]&format=svg`, err: "grid spacing"},
+		{query: `regions=[{"Top":10,"Height":100,"Pages":1000}]&format=svg`, err: "at most 100 pages"},
+		{query: `regions=[{"Top":10,"Height":100,"Grid":"dots","GridSpacing":5}]&format=svg`},
+	}
+	flags := lineaturFlags()
+	for _, test := range tests {
+		query, err := url.ParseQuery(test.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the format is the extension of the path
+		format := query.Get("format")
+		query.Del("format")
+		args, err := requestArguments(flags, query)
+		if err == nil {
+			_, err = serveSheet(context.Background(), append(args, "-format="+format))
+		}
+		switch {
+		case test.err == "" && err != nil:
+			t.Errorf("%s: %v", test.query, err)
+		case test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)):
+			t.Errorf("%s: got %v, want error %q", test.query, err, test.err)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/maptry/lineatur"
+)
+
+// wizardAnswers are the settings asked for by -i, the arguments given with
+// it are the first defaults.
+type wizardAnswers struct {
+	PaperSize   string
+	Script      string // a preset or proportions
+	LineHeight  string // empty for the one of the preset
+	Slants      string // empty for those of the preset, none for no slants
+	Margins     string
+	LineSpacing string // not asked, for the preview, empty for the one of the preset
+	Filename    string
+}
+
+// wizard asks the questions of -i on in and writes them to out.
+type wizard struct {
+	in  *bufio.Scanner
+	out io.Writer
+}
+
+// ask writes the question with the default and returns the answer, or the
+// default for an empty one. An answer check rejects is asked again.
+func (w *wizard) ask(question, def string, check func(answer string) error) (string, error) {
+	for {
+		fmt.Fprintf(w.out, "%s [%s]: ", question, def)
+		if !w.in.Scan() {
+			if err := w.in.Err(); err != nil {
+				return "", err
+			}
+			return "", errors.New("the input ended before all questions were answered")
+		}
+		answer := strings.TrimSpace(w.in.Text())
+		if answer == "" {
+			answer = def
+		}
+		if err := check(answer); err != nil {
+			fmt.Fprintf(w.out, "    %s\n", err)
+			continue
+		}
+		return answer, nil
+	}
+}
+
+// checkSlants accepts none, angle:number or angle:@spacing like -s.
+func checkSlants(s string) error {
+	if s == "none" {
+		return nil
+	}
+	if angle, spacing, ok := strings.Cut(s, ":@"); ok {
+		angles, err := parseMultiUint64(angle)
+		spacings, err2 := parseMultiFloat(spacing)
+		if err == nil && err2 == nil && len(angles) == 1 && len(spacings) == 1 && spacings[0] > 0 {
+			return nil
+		}
+	} else if slants, err := parseMultiUint64(s); err == nil && len(slants) == 2 {
+		return nil
+	}
+	return fmt.Errorf("wrong slants: %s, e.g. 55:10 for 10 slants of 55° per line or none", s)
+}
+
+// preview describes the zones and the lines per page of the answers.
+func preview(a wizardAnswers) string {
+	paperSize, _ := parseSize(a.PaperSize, 1)
+	vars := dimensionVariables(paperSize, 1)
+	if p, ok := lineatur.Presets[a.Script]; ok && p.Grid != "" {
+		return fmt.Sprintf("%s grid of %g mm", p.Grid, p.GridSpacing)
+	}
+	proportions, _ := parseMultiFloat(a.Script)
+	if p, ok := lineatur.Presets[a.Script]; ok {
+		proportions = p.Proportions
+	}
+	cfg := lineatur.Config{PaperSize: paperSize}
+	cfg.LineHeight, _ = evalDimension(a.LineHeight, vars, 1)
+	lineSpacing := a.LineSpacing
+	if p, ok := lineatur.Presets[a.Script]; ok && p.LineSpacing > 0 && lineSpacing == "" {
+		lineSpacing = fmt.Sprintf("%g", p.LineSpacing)
+	} else if lineSpacing == "" {
+		lineSpacing = "5"
+	}
+	cfg.LineSpacing, _ = evalDimension(lineSpacing, vars, 1)
+	cfg.Margins, _ = parseDimensions(a.Margins, vars, 1)
+	if len(proportions) == 0 {
+		return fmt.Sprintf("single lines, %d per page", cfg.LinesPerPage())
+	}
+	zones := []string{}
+	for _, d := range lineatur.ProportionsToLengths(proportions, cfg.LineHeight) {
+		zones = append(zones, fmt.Sprintf("%.1f", d))
+	}
+	return fmt.Sprintf("zones of %s mm, %d lines per page", strings.Join(zones, " + "), cfg.LinesPerPage())
+}
+
+// runWizard asks for the paper size, the script, the line height, the
+// slants, the margins and the output file, shows the zones and lines of the
+// page and returns the arguments of the answers once they are confirmed.
+func runWizard(in io.Reader, out io.Writer, a wizardAnswers) ([]string, error) {
+	w := &wizard{bufio.NewScanner(in), out}
+	fmt.Fprintf(w.out, "Answer the questions, an empty answer keeps the value in brackets, lengths are in mm.\n")
+	for {
+		var err error
+		if a.PaperSize, err = w.ask(fmt.Sprintf("Paper size, %s or WIDTHxHEIGHT", strings.Join(lineatur.PaperNames(), ", ")), a.PaperSize, func(s string) error {
+			_, err := parseSize(s, 1)
+			return err
+		}); err != nil {
+			return nil, err
+		}
+		paperSize, _ := parseSize(a.PaperSize, 1)
+		vars := dimensionVariables(paperSize, 1)
+		if a.Script, err = w.ask(fmt.Sprintf("Script preset, %s, or proportions like 3:4:3", strings.Join(lineatur.PresetNames(), ", ")), a.Script, func(s string) error {
+			if _, ok := lineatur.Presets[s]; ok {
+				return nil
+			}
+			if proportions, err := parseMultiFloat(s); err != nil || len(proportions) == 0 {
+				return fmt.Errorf("unknown preset or wrong proportions: %s", s)
+			}
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+		p, isPreset := lineatur.Presets[a.Script]
+		if p.Grid == "" {
+			lineHeight := a.LineHeight
+			if lineHeight == "" {
+				lineHeight = "10"
+				if p.LineHeight > 0 {
+					lineHeight = fmt.Sprintf("%g", p.LineHeight)
+				}
+			}
+			if a.LineHeight, err = w.ask("Line height", lineHeight, func(s string) error {
+				if v, err := evalDimension(s, vars, 1); err != nil || v <= 0 {
+					return fmt.Errorf("wrong line height: %s", s)
+				}
+				return nil
+			}); err != nil {
+				return nil, err
+			}
+			slants := a.Slants
+			if slants == "" {
+				slants = "none"
+				if isPreset && len(p.Slants) == 2 {
+					slants = fmt.Sprintf("%g:%g", p.Slants[0], p.Slants[1])
+				}
+			}
+			if a.Slants, err = w.ask("Slants, angle:number per line or none", slants, checkSlants); err != nil {
+				return nil, err
+			}
+		}
+		if a.Margins, err = w.ask("Margins, top:right:bottom:left", a.Margins, func(s string) error {
+			margins, err := parseDimensions(s, vars, 1)
+			if err != nil || len(margins) != 4 {
+				return fmt.Errorf("wrong margins: %s", s)
+			}
+			for _, m := range margins {
+				if m < 0 {
+					return fmt.Errorf("margins must not be negative: %s", s)
+				}
+			}
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+		if a.Filename, err = w.ask("Output file", a.Filename, func(s string) error {
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(w.out, "%s: %s\n", a.PaperSize, preview(a))
+		confirm, err := w.ask("Write the sheet, y or n to change the answers", "y", func(s string) error {
+			if s != "y" && s != "n" {
+				return errors.New("answer y or n")
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		if confirm == "y" {
+			break
+		}
+	}
+	args := []string{"-ps=" + a.PaperSize, "-m=" + a.Margins, "-o=" + a.Filename}
+	if p, ok := lineatur.Presets[a.Script]; ok {
+		args = append(args, "-preset="+a.Script)
+		if p.Grid != "" {
+			return args, nil
+		}
+	} else {
+		args = append(args, "-p="+a.Script)
+	}
+	args = append(args, "-lh="+a.LineHeight)
+	if a.Slants == "none" {
+		args = append(args, "-s=")
+	} else {
+		args = append(args, "-s="+a.Slants)
+	}
+	return args, nil
+}
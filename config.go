@@ -0,0 +1,753 @@
+package lineatur
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Config holds everything needed to draw the pages. All lengths are in mm.
+type Config struct {
+	PaperSize        PaperSize
+	Margins          []float64 // top, right, bottom, left
+	LineHeight       float64
+	LineSpacing      float64
+	Grow             float64 // increase of the line height from row to row
+	LineWidth        float64
+	LineWidths       []float64 // one per line from the top, empty = LineWidth
+	LineColor        Color
+	LineColors       []Color // one per line from the top, empty = LineColor
+	SlantColor       Color
+	EndDots          bool
+	Hanging          bool // the letters hang from the top line of the middle zone
+	Staff            bool // music staves of five lines instead of the lines, the line height is the staff height
+	GrandStaff       bool // the staves are joined in pairs
+	Rows             int  // at most that many lines per page, 0 = as many as fit
+	Lines            int  // the line height is fitted so that many lines fill the page between the margins, 0 = LineHeight
+	Distribute       bool // the space left below the last line is spread over the line spacing
+	Vertical         bool // the lines are columns top down from the right to the left, the line height is the column width
+	Cells            bool // the lines are divided into squares of the line height
+	Baseline         bool // the baseline is drawn bold
+	BaselineLine     int  // lines below the top line, 0 = the bottom line of the middle zone
+	BaselineColor    Color
+	BaselineWidth    float64 // width of the bold baseline, 0 = twice the line width
+	Proportions      []float64
+	Compare          []LabeledProportions // blocks of proportions stacked on the page
+	ZoneGaps         []float64            // one gap between each two zones
+	ZoneDims         string               // "first", "all" or empty
+	ZoneStyles       []string             // one per zone boundary from the top, the last is reused
+	ZoneColors       []Color              // one per zone, white zones are not tinted
+	ZoneOpacity      float64              // opacity of the zone colors from 0 to 1, 0 = opaque
+	RowTints         []Color              // background colors of the lines in turn, white lines are not tinted
+	SubLines         []int                // extra dotted lines dividing each zone evenly, one number per zone
+	UnitTicks        bool
+	LineNumbers      int    // every that many lines are numbered in the left margin, 0 = none
+	LineNumbersRight bool   // the line numbers are in the right margin
+	NibLadder        bool   // a square per unit of the proportions at the left of each line, for the nib width
+	Exemplar         string // written light on the first line of every block of lines to copy below it, empty = none
+	TraceText        string // written into the lines from the top to trace over, a newline starts a new line, empty = none
+	TraceFont        string // TrueType file of a dotted or outline font for the text to trace, empty = Font
+	TraceColor       Color
+	Title            string   // at the top margin of every page, empty = none
+	Header           []string // labels of the fields to fill in below the title, empty = none
+	Footer           bool     // page number and description of the ruling at the bottom of every page
+	QR               string   // text of a QR code in the bottom right corner of every page, e.g. the arguments making the sheet
+	QRSize           float64  // side of the QR code, 0 = the largest up to 12 mm with its quiet zone in the bottom margin
+	Preset           string   // name of the preset, for the footer
+	Font             string   // core font of the title and the header
+	TitleSize        float64  // font size of the title in points
+	Grid             string   // pattern filling the page instead of the lines, empty = lines
+	GridSpacing      float64
+	DotSize          float64 // diameter of the dots of the dot grid, 0 = twice the line width
+	GridMajor        int     // cells between the major lines of the squared grid, 0 = none
+	MajorWidth       float64
+	MajorColor       Color
+	Holes            int     // binder holes marked in the left margin: 0, 2 or 4, 3 = US
+	HoleOffset       float64 // of the holes down from the center of the page
+	Mirror           bool    // for left-handers: slants lean the other way, holes and margin line on the right
+	Duplex           bool    // the left and right margins are swapped on every second page
+	MarginLine       float64 // offset of a red vertical line from the left margin, right if mirrored, 0 = none
+	MarginLineColor  Color
+	CenterLine       bool      // a vertical line divides the lines in halves like on a steno pad
+	Cornell          []float64 // width of the cue column and height of the summary box in mm, nil = none
+	Order            []string  // layers from the bottom up, empty = Layers
+	Slants           []float64 // angle and number per line
+	SlantSpacing     float64   // mm between the slants instead of the number per line, 0 = the number
+	SlantsOverlay    bool
+	SlantsThrough    bool    // one block of slants through the gaps from the first to the last line, needs SlantSpacing
+	Warmup           string  // "wave", "loops" or empty
+	LoopGuides       float64 // distance of the ellipses spanning the rows, 0 = none
+	WarmupAmplitude  float64
+	WarmupLength     float64
+	Poster           PaperSize // empty if no poster is tiled
+	PosterOverlap    float64
+	Bleed            float64   // of the media beyond the trimmed sheet in mm, the vignette reaches into it
+	CropMarks        bool      // the sheet is printed on larger media with marks at its corners
+	Scale            []float64 // horizontal and vertical factor correcting the printer, the media is scaled around its center, nil = 1
+	Calibrate        bool      // only the calibration page with a 100 mm square and rulers to measure the printer
+	Spread           bool      // two pages side by side with a gutter in the middle
+	SpreadGutter     float64
+	Columns          int // the width is split into that many columns, 0 and 1 are one
+	ColumnGutter     float64
+	Booklet          int     // number of pages of a saddle-stitched booklet, 0 = none
+	Nup              int     // pages per sheet side: 2 side by side on the turned paper or 4 in two rows, 0 = one
+	Pages            int     // copies of the pages, 0 is one copy too
+	CenterCross      float64 // length of the crosshair at the page center
+	CenterCrossColor Color
+	Vignette         float64   // intensity from 0 to 1
+	FadeRight        float64   // fraction of the line width fading out toward the right
+	Watermark        string    // text behind the ruling, e.g. a school name, empty = none
+	WatermarkImage   string    // JPEG, PNG or GIF file behind the ruling, empty = none
+	WatermarkOpacity float64   // from 0 to 1, 0 = opaque
+	WatermarkAngle   float64   // counter-clockwise in degrees
+	WatermarkCenter  []float64 // x and y in mm from the top left corner, nil = the page center
+	WatermarkWidth   float64   // of the text and the image in mm, 0 = two thirds of the page width
+	PDFA             bool      // PDF/A-style metadata without a conformance claim, no unembedded fonts or transparency
+	Author           string    // of the document metadata
+	Deterministic    bool      // fixed date and producer without the version for byte-identical output
+	Limits           Limits    `json:"-"` // of the drawing, not part of the ruling
+	back             bool      // the page is the back of a duplex sheet
+}
+
+// LabeledProportions are line proportions with a label, see -compare.
+type LabeledProportions struct {
+	Label       string
+	Proportions []float64
+}
+
+// Validate reports all problems of the configuration at once.
+func (cfg Config) Validate() error {
+	problems := []error{}
+	if len(cfg.Margins) != 4 {
+		problems = append(problems, fmt.Errorf("wrong number of page margins: %d (top, right, bottom and left needed)", len(cfg.Margins)))
+	} else if cfg.Margins[0] < 0 || cfg.Margins[1] < 0 || cfg.Margins[2] < 0 || cfg.Margins[3] < 0 {
+		problems = append(problems, fmt.Errorf("page margins %v must not be negative", cfg.Margins))
+	} else if cfg.Margins[1]+cfg.Margins[3] >= cfg.canvas().Width || cfg.Margins[0]+cfg.Margins[2] >= cfg.canvas().Height {
+		problems = append(problems, fmt.Errorf("page margins %v leave no room on the paper", cfg.Margins))
+	}
+	if cfg.LineHeight <= 0 {
+		problems = append(problems, fmt.Errorf("line height must be greater than 0"))
+	}
+	if cfg.LineSpacing < 0 {
+		problems = append(problems, fmt.Errorf("line spacing must not be negative"))
+	}
+	if cfg.Grow < 0 {
+		problems = append(problems, errors.New("growth of the line height must not be negative"))
+	} else if cfg.Grow > 0 && len(cfg.Margins) == 4 && cfg.LineHeight > 0 && len(rowPositions(cfg)) < 2 {
+		problems = append(problems, fmt.Errorf("with a growth of %g mm per line not even two lines fit on the page", cfg.Grow))
+	}
+	if len(cfg.ZoneGaps) != 0 && len(cfg.ZoneGaps) != len(cfg.Proportions)-1 {
+		problems = append(problems, fmt.Errorf("wrong number of zone gaps: %d (one gap between each two zones of the %d proportions)", len(cfg.ZoneGaps), len(cfg.Proportions)))
+	}
+	for _, gap := range cfg.ZoneGaps {
+		if gap < 0 {
+			problems = append(problems, fmt.Errorf("zone gaps %v must not be negative", cfg.ZoneGaps))
+			break
+		}
+	}
+	// a wrong line height is reported above already
+	if cfg.LineHeight > 0 && sum(cfg.ZoneGaps) >= cfg.LineHeight {
+		problems = append(problems, fmt.Errorf("zone gaps of %g mm don't fit into the line height of %g mm", sum(cfg.ZoneGaps), cfg.LineHeight))
+	}
+	if cfg.ZoneDims != "" && cfg.ZoneDims != "first" && cfg.ZoneDims != "all" {
+		problems = append(problems, fmt.Errorf("unknown lines %s for the zone dimensions", cfg.ZoneDims))
+	}
+	for _, style := range cfg.ZoneStyles {
+		if !contains(LineStyles, style) {
+			problems = append(problems, fmt.Errorf("unknown line style %s", style))
+		}
+	}
+	if len(cfg.ZoneStyles) > len(cfg.Proportions)+1 {
+		problems = append(problems, fmt.Errorf("more zone styles than the %d lines of the line proportions", len(cfg.Proportions)+1))
+	}
+	if cfg.LineWidth <= 0 {
+		problems = append(problems, errors.New("line width must be greater than 0"))
+	}
+	if len(cfg.LineWidths) > len(cfg.Proportions)+1 {
+		problems = append(problems, fmt.Errorf("more line widths than the %d lines of the line proportions", len(cfg.Proportions)+1))
+	}
+	for _, w := range cfg.LineWidths {
+		if w <= 0 {
+			problems = append(problems, errors.New("line widths must be greater than 0"))
+			break
+		}
+	}
+	if len(cfg.LineColors) > len(cfg.Proportions)+1 {
+		problems = append(problems, fmt.Errorf("more line colors than the %d lines of the line proportions", len(cfg.Proportions)+1))
+	}
+	if len(cfg.ZoneColors) > len(cfg.Proportions) {
+		problems = append(problems, fmt.Errorf("more zone colors than the %d zones of the line proportions", len(cfg.Proportions)))
+	}
+	if cfg.ZoneOpacity < 0 || cfg.ZoneOpacity > 1 {
+		problems = append(problems, fmt.Errorf("opacity of the zone colors %g out of interval 0-1", cfg.ZoneOpacity))
+	}
+	if cfg.Grid != "" {
+		if !contains(GridPatterns, cfg.Grid) {
+			problems = append(problems, fmt.Errorf("unknown grid pattern %s, possible values: %s", cfg.Grid, strings.Join(GridPatterns, ", ")))
+		}
+		if cfg.GridSpacing <= 0 {
+			problems = append(problems, errors.New("spacing of the grid must be greater than 0"))
+		}
+		if cfg.GridMajor < 0 {
+			problems = append(problems, errors.New("number of cells between the major grid lines must not be negative"))
+		}
+		if cfg.GridMajor > 0 && cfg.MajorWidth <= 0 {
+			problems = append(problems, errors.New("width of the major grid lines must be greater than 0"))
+		}
+		if cfg.DotSize < 0 || cfg.DotSize > cfg.GridSpacing {
+			problems = append(problems, fmt.Errorf("dots of %g mm don't fit the grid spacing", cfg.DotSize))
+		}
+	}
+	if cfg.Vertical {
+		// the text and the helpers beside the lines would be turned sideways
+		for _, other := range []struct {
+			name string
+			set  bool
+		}{
+			{"a grid", cfg.Grid != ""}, {"staves", cfg.Staff}, {"compared blocks", len(cfg.Compare) > 0}, {"-cols", cfg.Columns > 1}, {"the Cornell layout", cfg.Cornell != nil},
+			{"slants", len(cfg.Slants) > 0}, {"a warm-up line", cfg.Warmup != ""}, {"loop guides", cfg.LoopGuides > 0}, {"unit ticks", cfg.UnitTicks}, {"zone dimensions", cfg.ZoneDims != ""},
+			{"line numbers", cfg.LineNumbers > 0}, {"an exemplar", cfg.Exemplar != ""}, {"text to trace", cfg.TraceText != ""}, {"a nib ladder", cfg.NibLadder}, {"binder holes", cfg.Holes > 0},
+		} {
+			if other.set {
+				problems = append(problems, fmt.Errorf("vertical columns can't have %s", other.name))
+			}
+		}
+	}
+	if cfg.Cells {
+		if len(cfg.Proportions) == 0 || cfg.Grid != "" || cfg.Staff {
+			problems = append(problems, errors.New("the cells divide lines with proportions, e.g. -p 1"))
+		}
+		if cfg.Grow != 0 {
+			problems = append(problems, errors.New("the cells are squares of the line height, it can't grow"))
+		}
+	}
+	if cfg.CenterLine && cfg.Columns > 1 {
+		problems = append(problems, errors.New("the center line divides the page into two columns, it can't be combined with -cols"))
+	}
+	if cfg.MarginLine < 0 {
+		problems = append(problems, errors.New("offset of the margin line must not be negative"))
+	} else if cfg.MarginLine > 0 && len(cfg.Margins) == 4 && cfg.MarginLine >= cfg.canvas().Width-cfg.Margins[1]-cfg.Margins[3] {
+		problems = append(problems, fmt.Errorf("margin line at %g mm lies outside the page margins", cfg.MarginLine))
+	}
+	if cfg.Cornell != nil {
+		if len(cfg.Cornell) != 2 {
+			problems = append(problems, errors.New("the Cornell layout needs the width of the cue column and the height of the summary"))
+		} else if cfg.Cornell[0] < 0 || cfg.Cornell[1] < 0 {
+			problems = append(problems, errors.New("the cue column and the summary of the Cornell layout must not be negative"))
+		} else if len(cfg.Margins) == 4 && (cfg.Cornell[0]+cornellGap >= cfg.canvas().Width-cfg.Margins[1]-cfg.Margins[3] || cfg.Cornell[1]+cornellGap >= cfg.canvas().Height-cfg.Margins[0]-cfg.Margins[2]) {
+			problems = append(problems, fmt.Errorf("the Cornell layout %g:%g leaves no room for the notes", cfg.Cornell[0], cfg.Cornell[1]))
+		}
+	}
+	if cfg.BaselineWidth < 0 {
+		problems = append(problems, errors.New("width of the baseline must not be negative"))
+	} else if cfg.BaselineWidth > 0 && !cfg.Baseline {
+		problems = append(problems, errors.New("the width of the baseline needs -baseline"))
+	}
+	if len(cfg.RowTints) > 0 && cfg.Grid != "" {
+		problems = append(problems, errors.New("a grid has no lines to tint"))
+	}
+	if cfg.BaselineLine < 0 || cfg.BaselineLine > len(cfg.Proportions) {
+		problems = append(problems, fmt.Errorf("baseline %d is not one of the %d lines below the top line of the line proportions", cfg.BaselineLine, len(cfg.Proportions)))
+	}
+	if cfg.Holes != 0 {
+		if cfg.Holes < 2 || cfg.Holes > 4 {
+			problems = append(problems, fmt.Errorf("binder holes %d aren't 2, 3 or 4", cfg.Holes))
+		} else if ys := holePositions(cfg.Holes, cfg.canvas().Height, cfg.HoleOffset); ys[0]-holeRadius < 0 || ys[len(ys)-1]+holeRadius > cfg.canvas().Height {
+			problems = append(problems, fmt.Errorf("%d binder holes with an offset of %g mm don't fit on the page", cfg.Holes, cfg.HoleOffset))
+		}
+		if len(cfg.Margins) == 4 && !cfg.Mirror && cfg.Margins[3] < holeDistance+holeRadius {
+			problems = append(problems, fmt.Errorf("binder holes need a left margin of at least %g mm", holeDistance+holeRadius))
+		}
+		if len(cfg.Margins) == 4 && cfg.Mirror && cfg.Margins[1] < holeDistance+holeRadius {
+			problems = append(problems, fmt.Errorf("mirrored binder holes need a right margin of at least %g mm", holeDistance+holeRadius))
+		}
+	}
+	if cfg.GrandStaff && !cfg.Staff {
+		problems = append(problems, errors.New("the grand staff needs -staff"))
+	}
+	if cfg.Rows < 0 {
+		problems = append(problems, errors.New("number of lines per page must not be negative"))
+	}
+	if cfg.Lines < 0 {
+		problems = append(problems, errors.New("number of lines to fit must not be negative"))
+	} else if cfg.Lines > 0 && cfg.Rows > 0 {
+		problems = append(problems, errors.New("the lines to fit already give the number of lines per page, -rows can't be added"))
+	}
+	if (cfg.Lines > 0 || cfg.Distribute) && cfg.Grid != "" {
+		problems = append(problems, errors.New("a grid has no lines to fit or distribute"))
+	} else if cfg.Lines > 0 && len(cfg.Margins) == 4 && cfg.LineHeight > 0 && cfg.LineSpacing >= 0 {
+		fitted := cfg
+		if cfg.Vertical {
+			fitted = cfg.vertical()
+		}
+		if fitted = fitted.fit(); fitted.LineHeight <= 0 || fitted.LineSpacing < 0 || len(rowPositions(fitted)) < cfg.Lines {
+			problems = append(problems, fmt.Errorf("%d lines don't fit between the margins", cfg.Lines))
+		}
+	}
+	if cfg.Staff && (cfg.Grid != "" || len(cfg.Compare) > 0 || cfg.SlantsOverlay) {
+		problems = append(problems, errors.New("music staves can't be combined with a grid, compared blocks or the slants overlay"))
+	}
+	for _, n := range cfg.SubLines {
+		if n < 0 {
+			problems = append(problems, errors.New("number of sub-lines must not be negative"))
+			break
+		}
+	}
+	if len(cfg.SubLines) > len(cfg.Proportions) {
+		problems = append(problems, fmt.Errorf("more sub-lines than the %d zones of the line proportions", len(cfg.Proportions)))
+	}
+	drawn := map[string]bool{}
+	for _, layer := range cfg.Order {
+		if !contains(Layers, layer) {
+			problems = append(problems, fmt.Errorf("unknown layer %s, possible values: %s", layer, strings.Join(Layers, ", ")))
+		} else if drawn[layer] {
+			problems = append(problems, fmt.Errorf("layer %s is drawn twice", layer))
+		}
+		drawn[layer] = true
+	}
+	if len(cfg.Slants) != 0 && len(cfg.Slants) != 2 {
+		problems = append(problems, fmt.Errorf("wrong number of arguments for the slanted helper lines: %d (angle and number per line needed)", len(cfg.Slants)))
+	} else if len(cfg.Slants) == 2 && cfg.SlantSpacing == 0 && cfg.Slants[1] < 1 {
+		problems = append(problems, errors.New("number of slanted helper lines per line must be at least 1"))
+	}
+	if cfg.SlantSpacing < 0 {
+		problems = append(problems, errors.New("spacing of the slanted helper lines must not be negative"))
+	}
+	if len(cfg.Slants) == 2 && (cfg.Slants[0] <= 0 || cfg.Slants[0] >= 180) {
+		problems = append(problems, fmt.Errorf("angle %g of the slanted helper lines out of interval 0-180 (exclusive)", cfg.Slants[0]))
+	}
+	if cfg.SlantsThrough && (len(cfg.Slants) != 2 || cfg.SlantSpacing == 0) {
+		problems = append(problems, errors.New("slants through the gaps need an angle and a spacing in mm (angle:@spacing)"))
+	}
+	if cfg.SlantsOverlay && len(cfg.Slants) == 0 {
+		problems = append(problems, errors.New("the slants overlay needs slanted helper lines"))
+	}
+	switch cfg.Warmup {
+	case "":
+	case "wave", "loops":
+		if cfg.WarmupLength <= 0 {
+			problems = append(problems, errors.New("wavelength of the warm-up pattern must be greater than 0"))
+		}
+	default:
+		problems = append(problems, fmt.Errorf("unknown warm-up pattern %s", cfg.Warmup))
+	}
+	if cfg.Poster != (PaperSize{}) {
+		if cfg.PosterOverlap < 0 || cfg.PosterOverlap >= math.Min(cfg.PaperSize.Width, cfg.PaperSize.Height)/2 {
+			problems = append(problems, fmt.Errorf("poster overlap of %g mm doesn't fit the paper size", cfg.PosterOverlap))
+		}
+	}
+	if cfg.Bleed < 0 {
+		problems = append(problems, errors.New("bleed must not be negative"))
+	} else if cfg.Bleed > 0 && cfg.Deterministic {
+		// gofpdf writes the trim and bleed boxes of a page in map order
+		problems = append(problems, errors.New("the bleed box can't be written deterministically, leave out -bleed or -deterministic"))
+	}
+	if (cfg.Bleed > 0 || cfg.CropMarks) && cfg.Poster != (PaperSize{}) {
+		problems = append(problems, errors.New("a poster can't have a bleed or crop marks, its tiles overlap"))
+	}
+	if len(cfg.Scale) > 0 {
+		if len(cfg.Scale) != 2 {
+			problems = append(problems, fmt.Errorf("the scale needs a horizontal and a vertical factor, got %d values", len(cfg.Scale)))
+		} else if cfg.Scale[0] < 0.5 || cfg.Scale[0] > 2 || cfg.Scale[1] < 0.5 || cfg.Scale[1] > 2 {
+			problems = append(problems, fmt.Errorf("scale %g:%g out of interval 0.5-2, it corrects small deviations of the printer", cfg.Scale[0], cfg.Scale[1]))
+		}
+	}
+	if cfg.Calibrate {
+		if cfg.Poster != (PaperSize{}) || cfg.Spread || cfg.Booklet > 0 || cfg.Nup > 1 {
+			problems = append(problems, errors.New("the calibration page can't be a poster, spread, booklet or n-up"))
+		}
+		if size := cfg.canvas(); size.Width < calibrationSize+20 || size.Height < calibrationSize+60 {
+			problems = append(problems, fmt.Errorf("the calibration square of %g mm doesn't fit on the paper", calibrationSize))
+		}
+		if cfg.PDFA {
+			problems = append(problems, errors.New("PDF/A needs embedded fonts, the calibration page can't be written"))
+		}
+	}
+	if cfg.LoopGuides < 0 {
+		problems = append(problems, errors.New("distance of the loop guides must not be negative"))
+	}
+	if cfg.PDFA && cfg.ZoneDims != "" {
+		problems = append(problems, errors.New("PDF/A needs embedded fonts, the zone dimensions can't be labeled"))
+	}
+	if cfg.PDFA && cfg.UnitTicks {
+		problems = append(problems, errors.New("PDF/A needs embedded fonts, the unit ticks can't be numbered"))
+	}
+	if (cfg.Title != "" || len(cfg.Header) > 0 || cfg.Watermark != "" || cfg.Exemplar != "" || cfg.TraceText != "" && cfg.TraceFont == "") && !contains(Fonts, cfg.Font) {
+		problems = append(problems, fmt.Errorf("unknown font %s, possible values: %s", cfg.Font, strings.Join(Fonts, ", ")))
+	}
+	if cfg.Title != "" || len(cfg.Header) > 0 {
+		if cfg.TitleSize <= 0 {
+			problems = append(problems, errors.New("font size of the title must be greater than 0"))
+		} else if len(cfg.Margins) == 4 && cfg.Margins[0]+cfg.titleHeight()+cfg.headerHeight()+cfg.Margins[2] >= cfg.canvas().Height {
+			problems = append(problems, fmt.Errorf("title and header of %g pt leave no room for the lines", cfg.TitleSize))
+		}
+	}
+	if cfg.Footer && cfg.PDFA {
+		problems = append(problems, errors.New("PDF/A needs embedded fonts, the footer can't be written"))
+	}
+	if cfg.Footer && len(cfg.Margins) == 4 && cfg.Margins[2] < footerDistance+1 && cfg.Poster == (PaperSize{}) {
+		problems = append(problems, fmt.Errorf("the footer needs a bottom margin of at least %g mm", footerDistance+1))
+	}
+	if cfg.QR != "" {
+		if modules, err := qrModules(cfg.QR); err != nil {
+			problems = append(problems, err)
+		} else if n := float64(len(modules)); cfg.QRSize == 0 && cfg.qrSize(len(modules)) < qrModule*n {
+			problems = append(problems, fmt.Errorf("the QR code of %d modules needs a bottom margin of at least %g mm to be readable", len(modules), qrModule*(n+2*qrQuietZone)))
+		} else if cfg.QRSize != 0 && cfg.QRSize < qrModule*n {
+			problems = append(problems, fmt.Errorf("the QR code of %d modules needs a size of at least %g mm to be readable", len(modules), qrModule*n))
+		} else if quiet := qrQuietZone * cfg.QRSize / n; cfg.QRSize != 0 && len(cfg.Margins) == 4 && cfg.Margins[2] < cfg.QRSize+2*quiet && cfg.Poster == (PaperSize{}) {
+			problems = append(problems, fmt.Errorf("the QR code needs a bottom margin of at least %.1f mm", math.Ceil((cfg.QRSize+2*quiet)*10)/10))
+		}
+	}
+	if cfg.Title != "" && cfg.PDFA {
+		problems = append(problems, errors.New("PDF/A needs embedded fonts, the title can't be written"))
+	}
+	if len(cfg.Header) > 0 && cfg.PDFA {
+		problems = append(problems, errors.New("PDF/A needs embedded fonts, the header can't be written"))
+	}
+	if cfg.LineNumbers < 0 {
+		problems = append(problems, errors.New("interval of the line numbers must not be negative"))
+	}
+	if cfg.LineNumbers > 0 {
+		if cfg.Grid != "" {
+			problems = append(problems, errors.New("the line numbers need lines, not a grid"))
+		}
+		if cfg.UnitTicks && !cfg.LineNumbersRight {
+			problems = append(problems, errors.New("the line numbers and the unit ticks share the left margin, put the numbers on the right"))
+		}
+		if cfg.ZoneDims != "" && cfg.LineNumbersRight {
+			problems = append(problems, errors.New("the line numbers and the zone dimensions share the right margin, put the numbers on the left"))
+		}
+		if cfg.PDFA {
+			problems = append(problems, errors.New("PDF/A needs embedded fonts, the lines can't be numbered"))
+		}
+	}
+	if cfg.NibLadder && len(cfg.Proportions) == 0 {
+		problems = append(problems, errors.New("the nib ladder needs line proportions"))
+	}
+	if cfg.UnitTicks && len(cfg.Proportions) == 0 {
+		problems = append(problems, errors.New("the unit ticks need line proportions"))
+	}
+	if cfg.Vignette < 0 || cfg.Vignette > 1 {
+		problems = append(problems, fmt.Errorf("vignette intensity %g out of interval 0-1", cfg.Vignette))
+	}
+	if cfg.PDFA && cfg.Vignette > 0 {
+		problems = append(problems, errors.New("PDF/A doesn't allow transparency, the vignette can't be drawn"))
+	}
+	if cfg.FadeRight < 0 || cfg.FadeRight > 1 {
+		problems = append(problems, fmt.Errorf("fade %g out of interval 0-1", cfg.FadeRight))
+	}
+	if cfg.PDFA && cfg.FadeRight > 0 {
+		problems = append(problems, errors.New("PDF/A doesn't allow transparency, the lines can't fade out"))
+	}
+	if cfg.PDFA && cfg.ZoneOpacity > 0 && cfg.ZoneOpacity < 1 {
+		problems = append(problems, errors.New("PDF/A doesn't allow transparency, the zone colors must be opaque"))
+	}
+	if cfg.Exemplar != "" {
+		if cfg.Grid != "" || cfg.Staff {
+			problems = append(problems, errors.New("the exemplar needs lines, not a grid or staves"))
+		}
+		if cfg.PDFA {
+			problems = append(problems, errors.New("PDF/A needs embedded fonts, the exemplar can't be written"))
+		}
+	}
+	if cfg.TraceText != "" {
+		if cfg.Grid != "" || cfg.Staff {
+			problems = append(problems, errors.New("the text to trace needs lines, not a grid or staves"))
+		}
+		if cfg.PDFA && cfg.TraceFont == "" {
+			problems = append(problems, errors.New("PDF/A needs embedded fonts, the text to trace needs a TrueType font"))
+		}
+	}
+	if cfg.TraceFont != "" && readTrueTypeFont(cfg.TraceFont).xHeight <= 0 {
+		problems = append(problems, fmt.Errorf("%s is no TrueType font with an x", cfg.TraceFont))
+	}
+	if cfg.Watermark != "" || cfg.WatermarkImage != "" {
+		if cfg.WatermarkOpacity < 0 || cfg.WatermarkOpacity > 1 {
+			problems = append(problems, fmt.Errorf("opacity of the watermark %g out of interval 0-1", cfg.WatermarkOpacity))
+		}
+		if cfg.WatermarkWidth < 0 {
+			problems = append(problems, errors.New("width of the watermark must not be negative"))
+		}
+		if cfg.WatermarkCenter != nil && len(cfg.WatermarkCenter) != 2 {
+			problems = append(problems, fmt.Errorf("the center of the watermark needs x and y, got %d values", len(cfg.WatermarkCenter)))
+		}
+		if cfg.PDFA && cfg.Watermark != "" {
+			problems = append(problems, errors.New("PDF/A needs embedded fonts, the watermark can't be written"))
+		}
+		if cfg.PDFA && cfg.WatermarkOpacity > 0 && cfg.WatermarkOpacity < 1 {
+			problems = append(problems, errors.New("PDF/A doesn't allow transparency, the watermark must be opaque"))
+		}
+	}
+	if len(cfg.Compare) > 0 {
+		if len(cfg.ZoneGaps) > 0 || len(cfg.ZoneColors) > 0 || cfg.UnitTicks {
+			problems = append(problems, errors.New("zone gaps, zone colors and unit ticks depend on the proportions and can't be used to compare blocks"))
+		}
+		if cfg.PDFA {
+			problems = append(problems, errors.New("PDF/A needs embedded fonts, the compared blocks can't be labeled"))
+		}
+		if len(cfg.Margins) == 4 {
+			blockHeight := (cfg.canvas().Height-cfg.Margins[0]-cfg.Margins[2])/float64(len(cfg.Compare)) - compareLabelHeight
+			if blockHeight < cfg.LineHeight {
+				problems = append(problems, fmt.Errorf("%d compared blocks don't fit on the page", len(cfg.Compare)))
+			}
+		}
+	}
+	if cfg.Spread {
+		if cfg.SpreadGutter < 0 || cfg.SpreadGutter >= cfg.PaperSize.Width {
+			problems = append(problems, fmt.Errorf("gutter of %g mm doesn't fit the paper size", cfg.SpreadGutter))
+		}
+		if cfg.Poster != (PaperSize{}) || cfg.Booklet > 0 {
+			problems = append(problems, errors.New("a spread can't be tiled as poster or imposed as booklet"))
+		}
+	}
+	if cfg.Pages < 0 {
+		problems = append(problems, errors.New("number of pages must not be negative"))
+	}
+	if cfg.Pages > 1 && cfg.Booklet > 0 {
+		problems = append(problems, errors.New("the booklet has its own number of pages, -pages can't be used"))
+	}
+	if cfg.Booklet < 0 {
+		problems = append(problems, errors.New("number of booklet pages must not be negative"))
+	}
+	if cfg.Booklet > 0 && cfg.Poster != (PaperSize{}) {
+		problems = append(problems, errors.New("a poster can't be imposed as booklet"))
+	}
+	if cfg.CenterCross < 0 {
+		problems = append(problems, errors.New("length of the center crosshair must not be negative"))
+	}
+	if cfg.Columns < 0 {
+		problems = append(problems, errors.New("number of columns must not be negative"))
+	}
+	if cfg.ColumnGutter < 0 {
+		problems = append(problems, errors.New("gutter between the columns must not be negative"))
+	} else if cfg.Columns > 1 && len(cfg.Margins) == 4 && float64(cfg.Columns-1)*cfg.ColumnGutter >= cfg.canvas().Width-cfg.Margins[1]-cfg.Margins[3] {
+		problems = append(problems, fmt.Errorf("%d columns with a gutter of %g mm leave no room for the lines", cfg.Columns, cfg.ColumnGutter))
+	}
+	if cfg.Duplex && (cfg.Booklet > 0 || cfg.Spread || cfg.Poster != (PaperSize{})) {
+		problems = append(problems, errors.New("duplex pages can't be imposed as booklet, spread or poster"))
+	}
+	switch cfg.Nup {
+	case 0, 1:
+	case 2, 4:
+		if cfg.Booklet > 0 || cfg.Spread || cfg.Poster != (PaperSize{}) || cfg.Duplex {
+			problems = append(problems, errors.New("n-up pages can't be imposed as booklet, spread, poster or duplex"))
+		}
+	default:
+		problems = append(problems, fmt.Errorf("%d pages per sheet can't be imposed, possible values: 2 or 4", cfg.Nup))
+	}
+	return errors.Join(problems...)
+}
+
+// hash returns a short hash identifying the configuration.
+func (cfg Config) hash() string {
+	// a struct of plain values always marshals
+	data, _ := json.Marshal(cfg)
+	return fmt.Sprintf("%x", sha256.Sum256(data))[:12]
+}
+
+// canvas returns the size of the area the lines are drawn on: the poster if
+// one is tiled, the two pages of a spread, a booklet or n-up page or
+// otherwise the paper.
+func (cfg Config) canvas() PaperSize {
+	if cfg.Poster != (PaperSize{}) {
+		return cfg.Poster
+	}
+	if cfg.Spread {
+		return PaperSize{2 * cfg.PaperSize.Width, cfg.PaperSize.Height}
+	}
+	if cfg.Booklet > 0 || cfg.Nup == 2 {
+		sheet := cfg.sheet()
+		return PaperSize{sheet.Width / 2, sheet.Height}
+	}
+	if cfg.Nup == 4 {
+		return PaperSize{cfg.PaperSize.Width / 2, cfg.PaperSize.Height / 2}
+	}
+	return cfg.PaperSize
+}
+
+// sheet returns the page size of the document: the paper turned to
+// landscape for a booklet or 2-up, otherwise the paper.
+func (cfg Config) sheet() PaperSize {
+	if (cfg.Booklet > 0 || cfg.Nup == 2) && cfg.PaperSize.Width < cfg.PaperSize.Height {
+		return PaperSize{cfg.PaperSize.Height, cfg.PaperSize.Width}
+	}
+	return cfg.PaperSize
+}
+
+// backPage returns the configuration of the back of a duplex sheet: the left
+// and right margins are swapped and the binder holes move to the right.
+func (cfg Config) backPage() Config {
+	cfg.Margins = []float64{cfg.Margins[0], cfg.Margins[3], cfg.Margins[2], cfg.Margins[1]}
+	cfg.back = true
+	return cfg
+}
+
+// slants returns the slanted helper lines, leaning the other way if mirrored.
+func (cfg Config) slants() []float64 {
+	if !cfg.Mirror || len(cfg.Slants) != 2 {
+		return cfg.Slants
+	}
+	return []float64{180 - cfg.Slants[0], cfg.Slants[1]}
+}
+
+// slantBlocks returns the top and the height of the blocks the slants are
+// drawn in: every line, or a single block from the first to the last line if
+// the slants run through the gaps.
+func (cfg Config) slantBlocks(rows []float64) [][2]float64 {
+	if cfg.SlantsThrough && len(rows) > 0 {
+		last := len(rows) - 1
+		return [][2]float64{{rows[0], rows[last] + cfg.rowHeight(last) - rows[0]}}
+	}
+	blocks := [][2]float64{}
+	for i, y := range rows {
+		blocks = append(blocks, [2]float64{y, cfg.rowHeight(i)})
+	}
+	return blocks
+}
+
+// vertical returns the configuration of the page turned a quarter clockwise,
+// its rows are the columns of the page from the right to the left.
+func (cfg Config) vertical() Config {
+	cfg.PaperSize = PaperSize{cfg.PaperSize.Height, cfg.PaperSize.Width}
+	if cfg.Poster != (PaperSize{}) {
+		cfg.Poster = PaperSize{cfg.Poster.Height, cfg.Poster.Width}
+	}
+	// the right margin is the top of the turned page
+	cfg.Margins = []float64{cfg.Margins[1], cfg.Margins[2], cfg.Margins[3], cfg.Margins[0]}
+	cfg.Vertical = false
+	return cfg
+}
+
+// staff returns the configuration of music manuscript paper: every line is a
+// staff without the guides of handwriting.
+func (cfg Config) staff() Config {
+	cfg.Proportions, cfg.ZoneGaps, cfg.ZoneColors, cfg.LineColors, cfg.LineWidths, cfg.SubLines = staffProportions, nil, nil, nil, nil, nil
+	cfg.Slants, cfg.Warmup, cfg.LoopGuides = nil, "", 0
+	cfg.Hanging, cfg.Baseline, cfg.UnitTicks, cfg.NibLadder = false, false, false, false
+	return cfg
+}
+
+// LinesPerPage returns the number of lines fitting on a page between the
+// margins.
+func (cfg Config) LinesPerPage() int {
+	return len(rowPositions(cfg.fit()))
+}
+
+// fitTolerance keeps fitted lines a hair inside the bottom margin, rowPositions
+// leaves out a line ending exactly on it.
+const fitTolerance = 1e-6
+
+// fit returns the configuration with the line height fitted to Lines lines
+// between the margins, or with Distribute the line spacing stretched so the
+// last line ends at the bottom margin.
+func (cfg Config) fit() Config {
+	height := cfg.canvas().Height - cfg.Margins[0] - cfg.Margins[2] - fitTolerance
+	n := cfg.Lines
+	if n > 0 && !cfg.Distribute {
+		// the heights of n grown lines add up to n line heights and the
+		// growth of 0+1+...+n-1 lines
+		cfg.LineHeight = (height - float64(n-1)*cfg.LineSpacing - cfg.Grow*float64(n*(n-1)/2)) / float64(n)
+	}
+	if cfg.Distribute {
+		if n == 0 {
+			n = len(rowPositions(cfg))
+		}
+		if n > 1 {
+			used := 0.0
+			for i := 0; i < n; i++ {
+				used += cfg.rowHeight(i)
+			}
+			cfg.LineSpacing = (height - used) / float64(n-1)
+		}
+	}
+	if n > 0 {
+		cfg.Rows = n
+	}
+	return cfg
+}
+
+// rowHeight returns the line height of the i-th row, growing down the page.
+func (cfg Config) rowHeight(i int) float64 {
+	return cfg.LineHeight + cfg.Grow*float64(i)
+}
+
+// titleHeight returns the space in mm the title takes from the top margin.
+func (cfg Config) titleHeight() float64 {
+	if cfg.Title == "" {
+		return 0
+	}
+	// the font size is in points
+	return cfg.TitleSize*25.4/72 + titleGap
+}
+
+// qrSize returns the side of the QR code of n modules, by default the
+// largest up to qrDefaultSize leaving the quiet zone above and below it in
+// the bottom margin.
+func (cfg Config) qrSize(n int) float64 {
+	if cfg.QRSize != 0 || len(cfg.Margins) != 4 {
+		return cfg.QRSize
+	}
+	return math.Min(qrDefaultSize, cfg.Margins[2]*float64(n)/float64(n+2*qrQuietZone))
+}
+
+// description sums up the ruling for the footer: the preset, the line
+// proportions, the slants and the line height.
+func (cfg Config) description() string {
+	format := func(values []float64, sep string) string {
+		strs := []string{}
+		for _, v := range values {
+			strs = append(strs, strconv.FormatFloat(v, 'g', -1, 64))
+		}
+		return strings.Join(strs, sep)
+	}
+	parts := []string{}
+	if cfg.Preset != "" {
+		parts = append(parts, cfg.Preset)
+	}
+	switch {
+	case cfg.Staff:
+		parts = append(parts, "staff")
+	case cfg.Grid != "":
+		parts = append(parts, fmt.Sprintf("%s grid %s mm", cfg.Grid, format([]float64{cfg.GridSpacing}, "")))
+	case len(cfg.Proportions) > 0:
+		parts = append(parts, format(cfg.Proportions, ":"))
+	}
+	if len(cfg.Slants) == 2 && cfg.SlantSpacing > 0 {
+		parts = append(parts, fmt.Sprintf("slants %s deg every %s mm", format(cfg.Slants[:1], ""), format([]float64{cfg.SlantSpacing}, "")))
+	} else if len(cfg.Slants) == 2 {
+		parts = append(parts, fmt.Sprintf("%s slants %s deg", format(cfg.Slants[1:], ""), format(cfg.Slants[:1], "")))
+	}
+	switch {
+	case cfg.Grid != "":
+	case cfg.Lines > 0 && !cfg.Distribute:
+		// the fitted line height depends on the margins of the page
+		parts = append(parts, fmt.Sprintf("%d lines", cfg.Lines))
+	default:
+		parts = append(parts, fmt.Sprintf("line height %s mm", format([]float64{cfg.LineHeight}, "")))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// headerHeight returns the space in mm the header fields take from the top
+// margin.
+func (cfg Config) headerHeight() float64 {
+	if len(cfg.Header) == 0 {
+		return 0
+	}
+	// the fields are written on a line below the labels' height
+	return cfg.TitleSize*25.4/72 + headerLineGap + titleGap
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,314 @@
+package lineatur
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+// DXFUnits are the supported DXF drawing units with their size in mm and
+// their $INSUNITS code.
+var DXFUnits = map[string]struct {
+	MM   float64
+	Code int
+}{
+	"mm": {1, 4},
+	"cm": {10, 5},
+	"in": {25.4, 1},
+}
+
+// DXF draws the pages as LINE, CIRCLE and TEXT entities of a DXF drawing
+// for CAD programs and laser cutters. Fills have no outline to cut and are
+// left out. Pages after the first are put below each other like in the SVG.
+type DXF struct {
+	paperSize  PaperSize
+	unit       float64 // mm per drawing unit
+	unitCode   int
+	layer      string
+	pages      int
+	footer     func()
+	entities   strings.Builder
+	pathX      float64
+	pathY      float64
+	path       [][4]float64
+	lineWidth  float64
+	lineCap    string
+	transforms transforms   // per TransformBegin
+	clips      [][4]float64 // rectangles per ClipRect on the page
+	metrics    *gofpdf.Fpdf
+}
+
+// NewDXF returns a DXF with pages of the paper size, drawn in the units on
+// the layer.
+func NewDXF(paperSize PaperSize, units string, layer string) *DXF {
+	metrics := gofpdf.New("P", "mm", "A4", "")
+	metrics.SetFont("Helvetica", "", 12)
+	return &DXF{
+		paperSize: paperSize,
+		unit:      DXFUnits[units].MM,
+		unitCode:  DXFUnits[units].Code,
+		layer:     layer,
+		metrics:   metrics,
+	}
+}
+
+// translate applies the open transforms.
+func (d *DXF) translate(x, y float64) (float64, float64) {
+	return d.transforms.apply(x, y)
+}
+
+// drawing converts page coordinates to drawing coordinates, the y axis of
+// the drawing points up.
+func (d *DXF) drawing(x, y float64) (float64, float64) {
+	y += float64(d.pages-1) * (d.paperSize.Height + pageGap)
+	return x / d.unit, -y / d.unit
+}
+
+func (d *DXF) point(x, y float64) (float64, float64) {
+	return d.drawing(d.translate(x, y))
+}
+
+// clipped reports whether the translated point is outside of a clip
+// rectangle.
+func (d *DXF) clipped(x, y float64) bool {
+	for _, c := range d.clips {
+		if x < c[0] || x > c[0]+c[2] || y < c[1] || y > c[1]+c[3] {
+			return true
+		}
+	}
+	return false
+}
+
+// clipLine cuts the line to the rectangle with the Liang-Barsky algorithm,
+// ok is false if nothing is left.
+func clipLine(x1, y1, x2, y2 float64, rect [4]float64) (float64, float64, float64, float64, bool) {
+	dx, dy := x2-x1, y2-y1
+	t0, t1 := 0.0, 1.0
+	for _, edge := range [][2]float64{
+		{-dx, x1 - rect[0]}, {dx, rect[0] + rect[2] - x1},
+		{-dy, y1 - rect[1]}, {dy, rect[1] + rect[3] - y1},
+	} {
+		p, q := edge[0], edge[1]
+		switch {
+		case p == 0:
+			if q < 0 {
+				return 0, 0, 0, 0, false
+			}
+		case p < 0:
+			t0 = math.Max(t0, q/p)
+		default:
+			t1 = math.Min(t1, q/p)
+		}
+	}
+	if t0 > t1 {
+		return 0, 0, 0, 0, false
+	}
+	return x1 + t0*dx, y1 + t0*dy, x1 + t1*dx, y1 + t1*dy, true
+}
+
+func (d *DXF) AddPage() {
+	if d.pages > 0 && d.footer != nil {
+		d.footer()
+	}
+	d.pages++
+}
+
+func (d *DXF) SetFooterFunc(fnc func()) {
+	d.footer = fnc
+}
+
+// SetLineWidth and SetLineCapStyle only make the round ends of lines without
+// length dots, the lines are drawn without a width.
+func (d *DXF) SetLineWidth(width float64) {
+	d.lineWidth = width
+}
+
+func (d *DXF) SetLineCapStyle(styleStr string) {
+	d.lineCap = styleStr
+}
+
+// SetDashPattern is ignored, the lines are drawn continuous.
+func (d *DXF) SetDashPattern(dashArray []float64, dashPhase float64) {}
+
+func (d *DXF) SetDrawColor(r, g, b int) {}
+
+func (d *DXF) SetFillColor(r, g, b int) {}
+
+func (d *DXF) SetAlpha(alpha float64, blendModeStr string) {}
+
+func (d *DXF) MoveTo(x, y float64) {
+	d.pathX, d.pathY = x, y
+}
+
+func (d *DXF) LineTo(x, y float64) {
+	d.path = append(d.path, [4]float64{d.pathX, d.pathY, x, y})
+	d.pathX, d.pathY = x, y
+}
+
+func (d *DXF) DrawPath(styleStr string) {
+	if strings.ToUpper(styleStr) != "F" {
+		for _, l := range d.path {
+			d.Line(l[0], l[1], l[2], l[3])
+		}
+	}
+	d.path = d.path[:0]
+}
+
+func (d *DXF) Line(x1, y1, x2, y2 float64) {
+	if x1 == x2 && y1 == y2 && d.lineCap == "round" {
+		d.Circle(x1, y1, d.lineWidth/2, "F")
+		return
+	}
+	x1, y1 = d.translate(x1, y1)
+	x2, y2 = d.translate(x2, y2)
+	for _, c := range d.clips {
+		var ok bool
+		if x1, y1, x2, y2, ok = clipLine(x1, y1, x2, y2, c); !ok {
+			return
+		}
+	}
+	x1, y1 = d.drawing(x1, y1)
+	x2, y2 = d.drawing(x2, y2)
+	fmt.Fprintf(&d.entities, "0\nLINE\n8\n%s\n10\n%.4f\n20\n%.4f\n11\n%.4f\n21\n%.4f\n", d.layer, x1, y1, x2, y2)
+}
+
+func (d *DXF) Rect(x, y, w, h float64, styleStr string) {
+	if strings.ToUpper(styleStr) == "F" {
+		return
+	}
+	d.Line(x, y, x+w, y)
+	d.Line(x+w, y, x+w, y+h)
+	d.Line(x+w, y+h, x, y+h)
+	d.Line(x, y+h, x, y)
+}
+
+// Circle is left out if its center is clipped.
+func (d *DXF) Circle(x, y, r float64, styleStr string) {
+	if d.clipped(d.translate(x, y)) {
+		return
+	}
+	x, y = d.point(x, y)
+	fmt.Fprintf(&d.entities, "0\nCIRCLE\n8\n%s\n10\n%.4f\n20\n%.4f\n40\n%.4f\n", d.layer, x, y, r*d.transforms.matrix().scale()/d.unit)
+}
+
+// Ellipse is approximated with lines, the ELLIPSE entity needs a newer DXF
+// version.
+func (d *DXF) Ellipse(x, y, rx, ry, degRotate float64, styleStr string) {
+	if strings.ToUpper(styleStr) == "F" {
+		return
+	}
+	const steps = 72
+	rotation := degRotate * math.Pi / 180
+	point := func(i int) (float64, float64) {
+		t := 2 * math.Pi * float64(i) / steps
+		ex, ey := rx*math.Cos(t), ry*math.Sin(t)
+		// counter-clockwise on the page, whose y axis points down
+		return x + ex*math.Cos(rotation) + ey*math.Sin(rotation), y - ex*math.Sin(rotation) + ey*math.Cos(rotation)
+	}
+	x1, y1 := point(0)
+	for i := 1; i <= steps; i++ {
+		x2, y2 := point(i)
+		d.Line(x1, y1, x2, y2)
+		x1, y1 = x2, y2
+	}
+}
+
+// AddUTF8FontFromBytes only measures with the font, the text is written in
+// the font of the CAD program.
+func (d *DXF) AddUTF8FontFromBytes(familyStr, styleStr string, utf8Bytes []byte) {
+	d.metrics.AddUTF8FontFromBytes(familyStr, styleStr, utf8Bytes)
+}
+
+func (d *DXF) SetFont(familyStr, styleStr string, size float64) {
+	d.metrics.SetFont(familyStr, styleStr, size)
+}
+
+func (d *DXF) GetFontSize() (ptSize, unitSize float64) {
+	return d.metrics.GetFontSize()
+}
+
+func (d *DXF) GetStringWidth(s string) float64 {
+	return d.metrics.GetStringWidth(s)
+}
+
+func (d *DXF) SetTextColor(r, g, b int) {}
+
+func (d *DXF) Text(x, y float64, txtStr string) {
+	if d.clipped(d.translate(x, y)) {
+		return
+	}
+	_, size := d.metrics.GetFontSize()
+	x, y = d.point(x, y)
+	// the cap height is about 0.7 of the font size
+	fmt.Fprintf(&d.entities, "0\nTEXT\n8\n%s\n10\n%.4f\n20\n%.4f\n40\n%.4f\n1\n%s\n", d.layer, x, y, 0.7*size*d.transforms.matrix().scale()/d.unit, txtStr)
+	if angle := d.transforms.matrix().angle(); angle != 0 {
+		fmt.Fprintf(&d.entities, "50\n%.4f\n", angle)
+	}
+}
+
+func (d *DXF) TransformBegin() {
+	d.transforms = append(d.transforms, identity)
+}
+
+func (d *DXF) TransformTranslate(tx, ty float64) {
+	d.transforms.add(translation(tx, ty))
+}
+
+func (d *DXF) TransformRotate(angle, x, y float64) {
+	d.transforms.add(rotation(angle, x, y))
+}
+
+func (d *DXF) TransformScale(scaleWd, scaleHt, x, y float64) {
+	d.transforms.add(scaling(scaleWd, scaleHt, x, y))
+}
+
+func (d *DXF) TransformEnd() {
+	d.transforms = d.transforms[:len(d.transforms)-1]
+}
+
+// ClipRect cuts the lines, circles and text are left out if their position
+// is clipped. A rotated rectangle clips to its bounding box.
+func (d *DXF) ClipRect(x, y, w, h float64, outline bool) {
+	if outline {
+		d.Rect(x, y, w, h, "D")
+	}
+	x, y, w, h = d.transforms.box(x, y, w, h)
+	d.clips = append(d.clips, [4]float64{x, y, w, h})
+}
+
+func (d *DXF) ClipEnd() {
+	d.clips = d.clips[:len(d.clips)-1]
+}
+
+// Image is left out like the fills, there is nothing to cut.
+func (d *DXF) Image(imageNameStr string, x, y, w, h float64, flow bool, tp string, link int, linkStr string) {
+}
+
+// Output writes the DXF drawing.
+func (d *DXF) Output(w io.Writer) error {
+	if d.footer != nil && d.pages > 0 {
+		d.footer()
+		d.footer = nil
+	}
+	if err := d.metrics.Error(); err != nil {
+		return err
+	}
+	height := math.Max(1, float64(d.pages))*(d.paperSize.Height+pageGap) - pageGap
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "0\nSECTION\n2\nHEADER\n")
+	fmt.Fprintf(b, "9\n$INSUNITS\n70\n%d\n", d.unitCode)
+	fmt.Fprintf(b, "9\n$EXTMIN\n10\n0.0\n20\n%.4f\n", -height/d.unit)
+	fmt.Fprintf(b, "9\n$EXTMAX\n10\n%.4f\n20\n0.0\n", d.paperSize.Width/d.unit)
+	fmt.Fprintf(b, "0\nENDSEC\n")
+	fmt.Fprintf(b, "0\nSECTION\n2\nTABLES\n0\nTABLE\n2\nLAYER\n70\n1\n")
+	fmt.Fprintf(b, "0\nLAYER\n2\n%s\n70\n0\n62\n7\n6\nCONTINUOUS\n", d.layer)
+	fmt.Fprintf(b, "0\nENDTAB\n0\nENDSEC\n")
+	fmt.Fprintf(b, "0\nSECTION\n2\nENTITIES\n")
+	b.WriteString(d.entities.String())
+	fmt.Fprintf(b, "0\nENDSEC\n0\nEOF\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
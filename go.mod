@@ -2,4 +2,10 @@ module github.com/maptry/lineatur
 
 go 1.20
 
-require github.com/jung-kurt/gofpdf v1.16.2
+require (
+	github.com/go-pdf/fpdf v0.9.0
+	github.com/jung-kurt/gofpdf v1.16.2
+	golang.org/x/image v0.20.0
+)
+
+require golang.org/x/text v0.18.0 // indirect
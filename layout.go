@@ -0,0 +1,167 @@
+package lineatur
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// LayoutPage is a page of a layout with its own configuration. A page with
+// regions only draws the regions.
+type LayoutPage struct {
+	Config  Config
+	Regions []LayoutRegion
+}
+
+// LayoutRegion is a part of a page from Top down to Top+Height, in mm from
+// the top edge, ruled with its own configuration. With a Width it spans from
+// Left to Left+Width, in mm from the left edge, instead of the page margins.
+type LayoutRegion struct {
+	Top, Height float64
+	Left, Width float64
+	Config      Config
+}
+
+// decodeStrict decodes the JSON into v, unknown fields are an error.
+func decodeStrict(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(v)
+}
+
+// ParseLayout reads a layout of pages from JSON, e.g.
+//
+//	{"Pages": [
+//	  {"Proportions": [2, 1, 2], "Slants": [60, 10], "LineHeight": 10},
+//	  {"Proportions": [3, 2, 3], "Slants": [52, 10], "LineHeight": 8},
+//	  {"Regions": [
+//	    {"Top": 10, "Height": 140, "Proportions": [3, 4, 3]},
+//	    {"Top": 160, "Height": 120, "Grid": "dots", "GridSpacing": 5}
+//	  ]},
+//	  {"Regions": [
+//	    {"Top": 10, "Height": 270, "Left": 10, "Width": 90, "Grid": "dots", "GridSpacing": 5},
+//	    {"Top": 10, "Height": 270, "Left": 110, "Width": 90, "Proportions": [3, 4, 3]}
+//	  ]}
+//	]}
+//
+// The fields are those of Config. Every page starts from the base
+// configuration and every region from its page. All pages are validated, they
+// share the paper size of the base and can't be posters, spreads or booklets.
+func ParseLayout(data []byte, base Config) ([]LayoutPage, error) {
+	var document struct {
+		Pages []json.RawMessage
+	}
+	if err := decodeStrict(data, &document); err != nil {
+		return nil, err
+	}
+	if len(document.Pages) == 0 {
+		return nil, errors.New("the layout has no pages")
+	}
+	layout := []LayoutPage{}
+	problems := []error{}
+	for i, rawPage := range document.Pages {
+		page := LayoutPage{Config: base}
+		regions := []json.RawMessage{}
+		// the fields of the page override its copy of the base
+		if err := decodeStrict(rawPage, &struct {
+			*Config
+			Regions *[]json.RawMessage
+		}{&page.Config, &regions}); err != nil {
+			return nil, fmt.Errorf("page %d: %s", i+1, err)
+		}
+		if page.Config.PaperSize != base.PaperSize {
+			problems = append(problems, fmt.Errorf("page %d: the pages of a layout have the paper size of the command line", i+1))
+		}
+		if page.Config.Bleed != base.Bleed || page.Config.CropMarks != base.CropMarks {
+			problems = append(problems, fmt.Errorf("page %d: the pages of a layout have the bleed and crop marks of the command line", i+1))
+		}
+		if page.Config.Poster != (PaperSize{}) || page.Config.Spread || page.Config.Booklet > 0 {
+			problems = append(problems, fmt.Errorf("page %d: a layout can't have posters, spreads or booklets", i+1))
+		}
+		if err := page.Config.Validate(); err != nil {
+			problems = append(problems, fmt.Errorf("page %d: %s", i+1, err))
+		}
+		for j, rawRegion := range regions {
+			region := LayoutRegion{Config: page.Config}
+			if err := decodeStrict(rawRegion, &struct {
+				*Config
+				Top, Height *float64
+				Left, Width *float64
+			}{&region.Config, &region.Top, &region.Height, &region.Left, &region.Width}); err != nil {
+				return nil, fmt.Errorf("page %d, region %d: %s", i+1, j+1, err)
+			}
+			height := page.Config.canvas().Height
+			if region.Top < 0 || region.Height <= 0 || region.Top+region.Height > height {
+				problems = append(problems, fmt.Errorf("page %d, region %d: %g mm from %g mm don't fit on the page", i+1, j+1, region.Height, region.Top))
+				continue
+			}
+			width := page.Config.canvas().Width
+			if region.Left > 0 && region.Width == 0 {
+				problems = append(problems, fmt.Errorf("page %d, region %d: Left needs the Width of the region", i+1, j+1))
+				continue
+			}
+			if region.Left < 0 || region.Width < 0 || region.Left+region.Width > width {
+				problems = append(problems, fmt.Errorf("page %d, region %d: %g mm wide from %g mm don't fit on the page", i+1, j+1, region.Width, region.Left))
+				continue
+			}
+			if len(region.Config.Margins) == 4 {
+				region.Config.Margins = []float64{region.Top, region.Config.Margins[1], height - region.Top - region.Height, region.Config.Margins[3]}
+				if region.Width > 0 {
+					region.Config.Margins[1], region.Config.Margins[3] = width-region.Left-region.Width, region.Left
+				}
+			}
+			if err := region.Config.Validate(); err != nil {
+				problems = append(problems, fmt.Errorf("page %d, region %d: %s", i+1, j+1, err))
+			}
+			page.Regions = append(page.Regions, region)
+		}
+		layout = append(layout, page)
+	}
+	return layout, errors.Join(problems...)
+}
+
+// DrawLayout adds the pages of the layout to the canvas, each with its own
+// configuration and repeated for a pad. The page numbers of the footer count
+// through the whole layout.
+func DrawLayout(pdf Canvas, layout []LayoutPage) {
+	current := Config{}
+	for _, page := range layout {
+		if page.Config.CenterCross > 0 || page.Config.Footer || page.Config.QR != "" {
+			setFooter(pdf, &current)
+			break
+		}
+	}
+	for _, page := range layout {
+		pages := pageFuncs(page.Config)
+		if len(page.Regions) > 0 {
+			regions := page.Regions
+			pages = pages[:0]
+			for i := 0; i < page.Config.Pages || i == 0; i++ {
+				pages = append(pages, func(pdf Canvas, cfg Config) {
+					for _, region := range regions {
+						DrawAll(pdf, region.Config)
+					}
+				})
+			}
+		}
+		for i, draw := range pages {
+			pdf.AddPage()
+			// the footer of the previous page is drawn by AddPage
+			current = page.Config
+			if page.Config.Duplex && i%2 == 1 {
+				current = page.Config.backPage()
+			}
+			draw(pdf, current)
+		}
+	}
+}
+
+// RenderLayout draws the pages of the layout like Render, the canvas is made
+// for the first page.
+func RenderLayout(layout []LayoutPage, format, dxfUnits, dxfLayer string, dpi float64) func(w io.Writer) error {
+	return render(layout[0].Config, format, dxfUnits, dxfLayer, dpi, func(pdf Canvas) {
+		DrawLayout(pdf, layout)
+	})
+}
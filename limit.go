@@ -0,0 +1,104 @@
+package lineatur
+
+import (
+	"context"
+	"errors"
+)
+
+// Limits bound the drawing of a sheet, e.g. of the sheets a server makes for
+// anyone asking.
+type Limits struct {
+	Context  context.Context // the drawing stops when it is done, nil = never
+	Elements int             // at most that many lines, shapes and texts, 0 = any number
+}
+
+// ErrTooManyElements stops a sheet drawing more elements than its limit.
+var ErrTooManyElements = errors.New("the sheet has too many elements")
+
+// limitCheckEvery is the number of elements drawn between two looks at the
+// context.
+const limitCheckEvery = 256
+
+// stopped is the panic ending a drawing at its limits, render recovers it.
+type stopped struct {
+	err error
+}
+
+// limited counts the elements drawn on the canvas and stops the drawing at
+// the limits.
+type limited struct {
+	Canvas
+	limits   Limits
+	elements int
+}
+
+func (l *limited) count() {
+	l.elements++
+	if l.limits.Elements > 0 && l.elements > l.limits.Elements {
+		panic(stopped{ErrTooManyElements})
+	}
+	if l.limits.Context != nil && l.elements%limitCheckEvery == 0 {
+		if err := l.limits.Context.Err(); err != nil {
+			panic(stopped{err})
+		}
+	}
+}
+
+// stop runs f and returns the error its drawing was stopped with.
+func stop(f func()) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			s, ok := r.(stopped)
+			if !ok {
+				panic(r)
+			}
+			err = s.err
+		}
+	}()
+	f()
+	return nil
+}
+
+func (l *limited) AddPage() {
+	if l.limits.Context != nil {
+		if err := l.limits.Context.Err(); err != nil {
+			panic(stopped{err})
+		}
+	}
+	l.Canvas.AddPage()
+}
+
+func (l *limited) Line(x1, y1, x2, y2 float64) {
+	l.count()
+	l.Canvas.Line(x1, y1, x2, y2)
+}
+
+func (l *limited) LineTo(x, y float64) {
+	l.count()
+	l.Canvas.LineTo(x, y)
+}
+
+func (l *limited) Rect(x, y, w, h float64, styleStr string) {
+	l.count()
+	l.Canvas.Rect(x, y, w, h, styleStr)
+}
+
+func (l *limited) Circle(x, y, r float64, styleStr string) {
+	l.count()
+	l.Canvas.Circle(x, y, r, styleStr)
+}
+
+func (l *limited) Ellipse(x, y, rx, ry, degRotate float64, styleStr string) {
+	l.count()
+	l.Canvas.Ellipse(x, y, rx, ry, degRotate, styleStr)
+}
+
+func (l *limited) Text(x, y float64, txtStr string) {
+	l.count()
+	l.Canvas.Text(x, y, txtStr)
+}
+
+func (l *limited) Image(imageNameStr string, x, y, w, h float64, flow bool, tp string, link int, linkStr string) {
+	l.count()
+	l.Canvas.Image(imageNameStr, x, y, w, h, flow, tp, link, linkStr)
+}
@@ -1,16 +1,23 @@
-package main
+// Package lineatur draws ruled guide sheets with lines in given proportions
+// and slanted helper lines for learning handwriting and older scripts, as
+// PDF, SVG, DXF or PNG. The command line tool is in cmd/lineatur.
+package lineatur
 
 import (
-	"flag"
 	"fmt"
+	"html"
+	"io"
 	"math"
-	"os"
+	"sort"
 	"strconv"
-	"strings"
+	"time"
 
 	"github.com/jung-kurt/gofpdf"
 )
 
+// Version is the version of lineatur, set at build time, see build.sh.
+var Version = "dev"
+
 // https://de.wikipedia.org/wiki/Lineatur
 // Winkel ist von der Grundlinie aus zur Schräge nach oben gemessen
 //    1:1:1 Sütterlinschrift (1915 - 1941)
@@ -19,21 +26,6 @@ import (
 //    2:1:2 Deutsche Kurrentschrift (60°)
 //    3:2:3 Copperplate (Winkel: 52°-60°)
 
-func usage() {
-	flag.PrintDefaults()
-	fmt.Fprintf(os.Stderr, "Line proportions: no argument = just one line\n")
-	fmt.Fprintf(os.Stderr, "Line proportions: num = two lines (the value doesn't matter)\n")
-	fmt.Fprintf(os.Stderr, "Line proportions: num[:num...]\n")
-	fmt.Fprintf(os.Stderr, "Slanted helper lines: \"num:num\" the angle and number per line of slanted helper lines\n")
-	fmt.Fprintf(os.Stderr, "Page margins: num:num:num:num top, right, bottom and left margins of the page in mm\n")
-	fmt.Fprintf(os.Stderr, "examples:\n")
-	fmt.Fprintf(os.Stderr, "    -p 2:1:2 -s 60:10  Deutsche Kurrentschrift\n")
-	fmt.Fprintf(os.Stderr, "    -p 1:1:1           Sütterlinschrift\n")
-	fmt.Fprintf(os.Stderr, "    -p 2:3:2 -s 75:10  Offenbacher Schrift\n")
-	fmt.Fprintf(os.Stderr, "    -p 3:4:3           Offenbacher Schrift, Lateinische Ausgangsschrift\n")
-	fmt.Fprintf(os.Stderr, "    -p 3:2:3 -s 52:10  Copperplate\n")
-}
-
 type PaperSize struct {
 	Width  float64 // mm
 	Height float64 // mm
@@ -48,57 +40,283 @@ var PaperSizes = map[string]PaperSize{
 	"Invoice": PaperSize{140.0, 216.0},
 	"Legal":   PaperSize{203.0, 330.0},
 	"Letter":  PaperSize{216.0, 279.0},
+	// landscape
+	"A5L":      PaperSize{210.0, 148.0},
+	"A4L":      PaperSize{297.0, 210.0},
+	"InvoiceL": PaperSize{216.0, 140.0},
+	"LegalL":   PaperSize{330.0, 203.0},
+	"LetterL":  PaperSize{279.0, 216.0},
+}
+
+// PaperNames returns the names of the paper sizes, sorted.
+func PaperNames() []string {
+	names := []string{}
+	for name := range PaperSizes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// pdfPageSize returns the orientation and the portrait page size gofpdf
+// expects for the paper size.
+func pdfPageSize(paperSize PaperSize) (string, gofpdf.SizeType) {
+	if paperSize.Width > paperSize.Height {
+		return "L", gofpdf.SizeType{Wd: paperSize.Height, Ht: paperSize.Width}
+	}
+	return "P", gofpdf.SizeType{Wd: paperSize.Width, Ht: paperSize.Height}
+}
+
+// SafeArea holds the non-printable borders of a printer. Margins smaller
+// than the safe area are widened so no guide line gets clipped.
+type SafeArea struct {
+	Top    float64 // mm
+	Right  float64 // mm
+	Bottom float64 // mm
+	Left   float64 // mm
+}
+
+// PrinterProfiles are typical non-printable borders taken from the printer
+// manuals, rounded up a little to be on the safe side.
+var PrinterProfiles = map[string]SafeArea{
+	"borderless":    SafeArea{0.0, 0.0, 0.0, 0.0},
+	"brother-laser": SafeArea{4.3, 4.3, 4.3, 4.3},
+	"canon-pixma":   SafeArea{3.0, 3.4, 5.0, 3.4},
+	"epson-inkjet":  SafeArea{3.0, 3.0, 3.0, 3.0},
+	"hp-deskjet":    SafeArea{3.2, 3.2, 12.7, 3.2},
+	"hp-laserjet":   SafeArea{4.3, 4.3, 4.3, 4.3},
+}
+
+// PrinterNames returns the names of the printer profiles, sorted.
+func PrinterNames() []string {
+	names := []string{}
+	for name := range PrinterProfiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Validate reports insets of the safe area that are negative.
+func (s SafeArea) Validate() error {
+	if s.Top < 0 || s.Right < 0 || s.Bottom < 0 || s.Left < 0 {
+		return fmt.Errorf("insets %g:%g:%g:%g of the safe area must not be negative", s.Top, s.Right, s.Bottom, s.Left)
+	}
+	return nil
+}
+
+// ApplySafeArea widens the margins (top, right, bottom, left) to at least
+// the non-printable borders of the safe area.
+func ApplySafeArea(margins []float64, safeArea SafeArea) []float64 {
+	insets := []float64{safeArea.Top, safeArea.Right, safeArea.Bottom, safeArea.Left}
+	result := make([]float64, len(margins))
+	for i, m := range margins {
+		result[i] = math.Max(m, insets[i])
+	}
+	return result
+}
+
+// Color is an RGB color with components from 0 to 255.
+type Color struct {
+	R, G, B int
+}
+
+// LineStyles are the styles lines can be drawn in.
+var LineStyles = []string{"solid", "dashed", "dotted"}
+
+// setLineStyle sets the dash pattern of the line style, the dots are as long
+// as the line is wide.
+func setLineStyle(pdf Canvas, style string, lineWidth float64) {
+	switch style {
+	case "dashed":
+		pdf.SetDashPattern([]float64{2, 1.5}, 0)
+	case "dotted":
+		pdf.SetDashPattern([]float64{lineWidth, 1}, 0)
+	default:
+		pdf.SetDashPattern([]float64{}, 0)
+	}
+}
+
+// BoundaryStyle returns the style of the i-th zone boundary from the top, the last style
+// is reused when fewer styles than boundaries are given.
+func BoundaryStyle(styles []string, i int) string {
+	if len(styles) == 0 {
+		return "solid"
+	}
+	if i >= len(styles) {
+		return styles[len(styles)-1]
+	}
+	return styles[i]
+}
+
+// fadeSegments is the number of segments the faded end of a line is drawn with.
+const fadeSegments = 20
+
+// drawHorizontal draws a horizontal line, optionally ending in filled dots.
+func drawHorizontal(pdf Canvas, x, y, width float64, lineWidth float64, style string, endDots bool, fade float64) {
+	setLineStyle(pdf, style, lineWidth)
+	solid := width * (1 - fade)
+	if solid > 0 {
+		pdf.MoveTo(x, y)
+		pdf.LineTo(x+solid, y)
+		pdf.DrawPath("D")
+	}
+	if fade > 0 {
+		// the rest of the line gets lighter segment by segment
+		step := (width - solid) / fadeSegments
+		for i := 0; i < fadeSegments; i++ {
+			pdf.SetAlpha(1-(float64(i)+0.5)/fadeSegments, "Normal")
+			pdf.MoveTo(x+solid+step*float64(i), y)
+			pdf.LineTo(x+solid+step*float64(i+1), y)
+			pdf.DrawPath("D")
+		}
+		pdf.SetAlpha(1, "Normal")
+	}
+	// everything else is solid
+	setLineStyle(pdf, "solid", lineWidth)
+	if endDots {
+		pdf.Circle(x, y, 2*lineWidth, "F")
+		// a faded line has no end
+		if fade == 0 {
+			pdf.Circle(x+width, y, 2*lineWidth, "F")
+		}
+	}
 }
 
-func parseMultiUint64(s string) ([]float64, error) {
-	if s == "" {
-		return nil, nil
+// drawZoneColors tints the zones of a line, white zones are left out.
+func drawZoneColors(pdf Canvas, x, y, width float64, lineDists []float64, zoneGaps []float64, zoneColors []Color, opacity float64) {
+	if opacity > 0 && opacity < 1 {
+		pdf.SetAlpha(opacity, "Normal")
+		defer pdf.SetAlpha(1, "Normal")
 	}
-	strs := strings.Split(s, ":")
-	values := []float64{}
-	for _, m := range strs {
-		u, err := strconv.ParseUint(m, 10, 64)
-		if err != nil {
-			return nil, err
+	for i, offset := range zoneOffsets(lineDists, zoneGaps) {
+		if i >= len(zoneColors) || zoneColors[i] == White {
+			continue
 		}
-		values = append(values, float64(u))
+		pdf.SetFillColor(zoneColors[i].R, zoneColors[i].G, zoneColors[i].B)
+		pdf.Rect(x, y+offset, width, lineDists[i], "F")
 	}
-	return values, nil
+	pdf.SetFillColor(0, 0, 0)
 }
 
-func drawLineatur(pdf *gofpdf.Fpdf, x, y, lineHeight, width float64, lineDists []float64, lineWidth float64, slants []float64) {
+// hangingWidth is the width of the hanging line relative to the other lines.
+const hangingWidth = 3.0
+
+func drawLineatur(pdf Canvas, x, y, lineHeight, width float64, lineDists []float64, zoneGaps []float64, lineWidth float64, zoneStyles []string, colors []Color, widths []float64, endDots bool, fade float64, hanging bool) {
+	// the lines without a color or width of their own keep the current
+	// color and the line width
+	setColor := func(i int) {
+		if i < len(colors) {
+			pdf.SetDrawColor(colors[i].R, colors[i].G, colors[i].B)
+			pdf.SetFillColor(colors[i].R, colors[i].G, colors[i].B)
+		}
+	}
+	widthOf := func(i int) float64 {
+		if i < len(widths) {
+			return widths[i]
+		}
+		return lineWidth
+	}
 	pdf.SetLineWidth(lineWidth)
 	switch len(lineDists) {
 	case 0:
-		pdf.MoveTo(x, y+lineHeight)
-		pdf.LineTo(x+width, y+lineHeight)
-		pdf.DrawPath("D")
+		// a single line is the baseline below the letters or the line they hang from
+		if !hanging {
+			pdf.SetLineWidth(widthOf(0))
+			drawHorizontal(pdf, x, y+lineHeight, width, widthOf(0), BoundaryStyle(zoneStyles, 0), endDots, fade)
+		}
 	default:
 		_y := y
-		pdf.MoveTo(x, _y)
-		pdf.LineTo(x+width, _y)
-		pdf.DrawPath("D")
-		for _, d := range lineDists {
+		setColor(0)
+		pdf.SetLineWidth(widthOf(0))
+		drawHorizontal(pdf, x, _y, width, widthOf(0), BoundaryStyle(zoneStyles, 0), endDots, fade)
+		for i, d := range lineDists {
 			_y += d
-			pdf.MoveTo(x, _y)
-			pdf.LineTo(x+width, _y)
-			pdf.DrawPath("D")
+			style := BoundaryStyle(zoneStyles, i+1)
+			setColor(i + 1)
+			pdf.SetLineWidth(widthOf(i + 1))
+			drawHorizontal(pdf, x, _y, width, widthOf(i+1), style, endDots, fade)
+			// the next zone starts after the unruled gap
+			if i < len(zoneGaps) && zoneGaps[i] > 0 {
+				_y += zoneGaps[i]
+				drawHorizontal(pdf, x, _y, width, widthOf(i+1), style, endDots, fade)
+			}
 		}
-		// draw lines left and right
-		pdf.MoveTo(x, y)
-		pdf.LineTo(x, y+lineHeight)
-		pdf.DrawPath("D")
+	}
+	if hanging {
+		// the letters hang from the top line of the middle zone
+		offset, _ := xHeightZone(lineHeight, lineDists, zoneGaps)
+		setColor((len(lineDists) - 1) / 2)
+		pdf.SetLineWidth(hangingWidth * lineWidth)
+		drawHorizontal(pdf, x, y+offset, width, lineWidth, "solid", false, fade)
+	}
+	pdf.SetLineWidth(lineWidth)
+}
+
+// drawSubLines divides the zones evenly by thin dotted helper lines, sub
+// holds the number of extra lines per zone from the top.
+func drawSubLines(pdf Canvas, x, y, width float64, lineDists []float64, zoneGaps []float64, sub []int, lineWidth float64, fade float64) {
+	offsets := zoneOffsets(lineDists, zoneGaps)
+	pdf.SetLineWidth(lineWidth / 2)
+	for i, n := range sub {
+		if i >= len(lineDists) {
+			break
+		}
+		for j := 1; j <= n; j++ {
+			drawHorizontal(pdf, x, y+offsets[i]+lineDists[i]*float64(j)/float64(n+1), width, lineWidth/2, "dotted", false, fade)
+		}
+	}
+	pdf.SetLineWidth(lineWidth)
+}
+
+// drawBorders draws the lines left and right of a line with zones.
+func drawBorders(pdf Canvas, x, y, lineHeight, width float64, lineDists []float64, lineWidth float64, fade float64) {
+	if len(lineDists) == 0 {
+		return
+	}
+	pdf.SetLineWidth(lineWidth)
+	pdf.MoveTo(x, y)
+	pdf.LineTo(x, y+lineHeight)
+	pdf.DrawPath("D")
+	if fade == 0 {
 		pdf.MoveTo(x+width, y)
 		pdf.LineTo(x+width, y+lineHeight)
 		pdf.DrawPath("D")
 	}
-	// draw slanted helper lines
+}
+
+// drawSlants draws the slanted helper lines of a single line.
+func drawSlants(pdf Canvas, x, y, lineHeight, width float64, slants []float64, spacing, fade float64) {
 	if len(slants) == 2 {
 		angle := math.Pi * (90.0 - slants[0]) / 180.0
 		b := math.Abs(lineHeight * math.Tan(angle))
-		n := (width - b) / (slants[1] - 1)
-		for i := 0.0; i < slants[1]; i++ {
-			_x := x + n*i
+		starts := []float64{}
+		switch {
+		case spacing > 0:
+			// the slants keep their spacing from the left border, those
+			// sticking out at the ends are cut at the borders
+			for k := -math.Floor(b / spacing); x+k*spacing < x+width; k++ {
+				starts = append(starts, x+k*spacing)
+			}
+		case slants[1] == 1:
+			// a single slant is centered in the line
+			starts = append(starts, x+(width-b)/2)
+		default:
+			n := (width - b) / (slants[1] - 1)
+			for i := 0.0; i < slants[1]; i++ {
+				starts = append(starts, x+n*i)
+			}
+		}
+		if b > width || spacing > 0 {
+			// slants flatter than the line is wide are cut at its borders
+			pdf.ClipRect(x, y, width, lineHeight, false)
+			defer pdf.ClipEnd()
+		}
+		for _, _x := range starts {
+			// slants in the faded end of the line fade with it
+			if start := width * (1 - fade); fade > 0 && _x+b/2-x > start {
+				pdf.SetAlpha(math.Max(0, 1-(_x+b/2-x-start)/(width-start)), "Normal")
+			}
 			if slants[0] <= 90 {
 				pdf.MoveTo(_x, y+lineHeight)
 				pdf.LineTo(_x+b, y)
@@ -108,10 +326,13 @@ func drawLineatur(pdf *gofpdf.Fpdf, x, y, lineHeight, width float64, lineDists [
 			}
 			pdf.DrawPath("D")
 		}
+		pdf.SetAlpha(1, "Normal")
 	}
 }
 
-func proportionsToLengths(proportions []float64, lineHeight float64) []float64 {
+// ProportionsToLengths divides the line height into the zone heights of the
+// proportions.
+func ProportionsToLengths(proportions []float64, lineHeight float64) []float64 {
 	lineDists := []float64{}
 	// sum of proportions
 	sumProp := 0.0
@@ -125,70 +346,1240 @@ func proportionsToLengths(proportions []float64, lineHeight float64) []float64 {
 	return lineDists
 }
 
-func drawAllLineatur(pdf *gofpdf.Fpdf, paperSize PaperSize, margins []float64, lineHeight float64, lineSpacing float64, proportions []float64, slants []float64, lineWidth float64) {
-	lineDists := proportionsToLengths(proportions, lineHeight)
-	width := paperSize.Width - margins[1] - margins[3]
-	x := margins[3]
-	y := margins[0]
-	for (y + lineHeight) < (paperSize.Height - margins[2]) {
-		drawLineatur(pdf, x, y, lineHeight, width, lineDists, lineWidth, slants)
-		y += lineHeight + lineSpacing
-	}
-}
-
-func main() {
-	var paperSize, _proportions, _slants, _margins, filename string
-	var lineHeight, lineSpacing uint64
-	var lineWidth float64
-	flag.StringVar(&filename, "o", "output.pdf", "output file")
-	flag.StringVar(&paperSize, "ps", "A4", "Paper size of your printer. Possible values: A5, A4, Invoice, Legal, Letter. Print without scaling.")
-	flag.StringVar(&_proportions, "p", "", "Line proportions.")
-	flag.StringVar(&_slants, "s", "", "Slanted helper lines.")
-	flag.StringVar(&_margins, "m", "5:15:15:5", "Page margins.")
-	flag.Uint64Var(&lineHeight, "lh", 10, "Line height in mm.")
-	flag.Uint64Var(&lineSpacing, "ls", 5, "Line spacing in mm.")
-	flag.Float64Var(&lineWidth, "lw", 0.3, "Line width in mm.")
-	flag.Usage = usage
-	flag.Parse()
-	if _, ok := PaperSizes[paperSize]; !ok {
-		fmt.Printf("paper size \"%s\" choosen for printing is unknown/not allowed\n", paperSize)
-		os.Exit(1)
-	}
-	proportions, err := parseMultiUint64(_proportions)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "wrong arguments for -p: %s\n", _proportions)
-		os.Exit(1)
+// zoneOffsets returns the offset of the top of every zone from the top of the line.
+func zoneOffsets(lineDists []float64, zoneGaps []float64) []float64 {
+	offsets := []float64{}
+	offset := 0.0
+	for i, d := range lineDists {
+		offsets = append(offsets, offset)
+		offset += d
+		if i < len(zoneGaps) {
+			offset += zoneGaps[i]
+		}
 	}
-	slants, err := parseMultiUint64(_slants)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "wrong arguments for -s: %s\n", _slants)
-		os.Exit(1)
+	return offsets
+}
+
+// xHeightZone returns the offset from the top of the line and the height of
+// the zone the lowercase letters are written in: the middle zone.
+func xHeightZone(lineHeight float64, lineDists []float64, zoneGaps []float64) (float64, float64) {
+	if len(lineDists) == 0 {
+		return 0, lineHeight
+	}
+	i := (len(lineDists) - 1) / 2
+	return zoneOffsets(lineDists, zoneGaps)[i], lineDists[i]
+}
+
+// baselineWidth is the width of the emphasized baseline relative to the other
+// lines.
+const baselineWidth = 2.0
+
+// baselineOffset returns the offset from the top of the line of the boundary
+// line of the zones, 0 is the bottom line of the middle zone. Without zones it
+// is the single line at the bottom.
+func baselineOffset(lineHeight float64, lineDists []float64, zoneGaps []float64, line int) float64 {
+	if len(lineDists) == 0 {
+		return lineHeight
+	}
+	if line == 0 {
+		line = (len(lineDists)-1)/2 + 1
 	}
-	if len(slants) != 0 && len(slants) != 2 {
-		fmt.Fprintf(os.Stderr, "wrong number of arguments for -s: %s\n", _slants)
-		os.Exit(1)
+	// a line below a gap is the bottom of the zone above it
+	return zoneOffsets(lineDists, zoneGaps)[line-1] + lineDists[line-1]
+}
+
+// drawWarmup draws a wave or loop pattern into the x-height zone of a line
+// as a tracing exercise. The curve is approximated by short straight segments.
+func drawWarmup(pdf Canvas, x, y, width, zoneHeight float64, pattern string, amplitude, wavelength, lineWidth float64) {
+	amplitude = math.Min(amplitude, zoneHeight/2)
+	yc := y + zoneHeight/2
+	// radians per mm along the line
+	k := 2 * math.Pi / wavelength
+	pdf.SetDrawColor(160, 160, 160)
+	pdf.SetLineWidth(lineWidth)
+	switch pattern {
+	case "wave":
+		steps := int(math.Ceil(width * 36 / wavelength))
+		pdf.MoveTo(x, yc)
+		for i := 1; i <= steps; i++ {
+			_x := x + width*float64(i)/float64(steps)
+			pdf.LineTo(_x, yc-amplitude*math.Sin(k*(_x-x)))
+		}
+	case "loops":
+		// a prolate cycloid, the loops overlap by half their width
+		r := 1.5 / k
+		tEnd := (width - 2*r) * k
+		steps := int(math.Ceil(tEnd * 36 / (2 * math.Pi)))
+		for i := 0; i <= steps; i++ {
+			t := tEnd * float64(i) / float64(steps)
+			_x := x + r + t/k - r*math.Sin(t)
+			_y := yc + amplitude*math.Cos(t)
+			if i == 0 {
+				pdf.MoveTo(_x, _y)
+			} else {
+				pdf.LineTo(_x, _y)
+			}
+		}
+	}
+	pdf.DrawPath("D")
+	pdf.SetDrawColor(0, 0, 0)
+}
+
+// loopGuideRatio is the width of a loop guide relative to its height.
+const loopGuideRatio = 1.0 / 3
+
+// drawLoopGuides draws ellipses spanning the row from the top to the bottom
+// line, the given distance apart and leaning like the slanted helper lines.
+func drawLoopGuides(pdf Canvas, x, y, lineHeight, width, distance float64, slants []float64, lineWidth float64) {
+	rotation := 0.0
+	if len(slants) == 2 {
+		rotation = slants[0] - 90
+	}
+	// the leaning ellipse still touches the top and bottom line
+	r := rotation * math.Pi / 180
+	ry := lineHeight / 2 / math.Sqrt(math.Pow(math.Cos(r), 2)+math.Pow(loopGuideRatio*math.Sin(r), 2))
+	rx := ry * loopGuideRatio
+	// the horizontal extent of the leaning ellipse
+	half := math.Sqrt(math.Pow(rx*math.Cos(r), 2) + math.Pow(ry*math.Sin(r), 2))
+	pdf.SetDrawColor(190, 190, 190)
+	pdf.SetLineWidth(lineWidth)
+	for _x := x + half; _x+half <= x+width; _x += distance {
+		pdf.Ellipse(_x, y+lineHeight/2, rx, ry, rotation, "D")
+	}
+	pdf.SetDrawColor(0, 0, 0)
+}
+
+// drawZoneDims labels every zone of a line with its height, vertically
+// centered to the zone.
+func drawZoneDims(pdf Canvas, x, y float64, lineDists []float64, zoneGaps []float64) {
+	pdf.SetFont("Helvetica", "", 6)
+	_, fontHeight := pdf.GetFontSize()
+	for i, offset := range zoneOffsets(lineDists, zoneGaps) {
+		pdf.Text(x, y+offset+lineDists[i]/2+fontHeight/3, fmt.Sprintf("%.1f mm", lineDists[i]))
+	}
+}
+
+// lineNumberGap is the distance in mm of the line numbers from the lines.
+const lineNumberGap = 2.0
+
+// drawLineNumber writes the number of a line at the height of its baseline
+// into the left margin of the lines from x to x+width, or the right one.
+func drawLineNumber(pdf Canvas, x, width, baseline float64, number int, right bool) {
+	pdf.SetFont("Helvetica", "", 7)
+	label := strconv.Itoa(number)
+	if right {
+		pdf.Text(x+width+lineNumberGap, baseline, label)
+	} else {
+		pdf.Text(x-lineNumberGap-pdf.GetStringWidth(label), baseline, label)
+	}
+}
+
+// drawUnitTicks marks every unit of the proportions of a line with a tick and
+// numbers the units from the top, left of x.
+func drawUnitTicks(pdf Canvas, x, y float64, proportions []float64, lineDists []float64, zoneGaps []float64) {
+	pdf.SetFont("Helvetica", "", 5)
+	pdf.SetLineWidth(0.1)
+	_, fontHeight := pdf.GetFontSize()
+	unit := 0
+	for i, offset := range zoneOffsets(lineDists, zoneGaps) {
+		unitLength := lineDists[i] / proportions[i]
+		for u := 0.0; u < proportions[i]; u++ {
+			_y := y + offset + u*unitLength
+			unit++
+			pdf.Line(x-1.5, _y, x, _y)
+			label := strconv.Itoa(unit)
+			pdf.Text(x-2-pdf.GetStringWidth(label), _y+unitLength/2+fontHeight/3, label)
+		}
+		pdf.Line(x-1.5, y+offset+lineDists[i], x, y+offset+lineDists[i])
 	}
-	/*
-		if len(slants) == 2 && (slants[0] > 90) {
-			fmt.Fprintf(os.Stderr, "value out of interval for parameter -s: %s\n", _slants)
-			os.Exit(1)
+}
+
+// drawNibLadder stacks a filled square per unit of the proportions of a line
+// at x, alternating between two columns like the marks made with the nib
+// turned by 90°. A fraction of a unit gets a cut square.
+func drawNibLadder(pdf Canvas, x, y float64, proportions []float64, lineDists []float64, zoneGaps []float64) {
+	column := 0
+	for i, offset := range zoneOffsets(lineDists, zoneGaps) {
+		unitLength := lineDists[i] / proportions[i]
+		for u := 0.0; u < proportions[i]; u++ {
+			height := math.Min(1, proportions[i]-u) * unitLength
+			pdf.Rect(x+float64(column%2)*unitLength, y+offset+u*unitLength, unitLength, height, "F")
+			column++
 		}
-	*/
-	margins, err := parseMultiUint64(_margins)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "wrong arguments for -m: %s\n", _margins)
-		os.Exit(1)
 	}
-	if len(margins) != 0 && len(margins) != 4 {
-		fmt.Fprintf(os.Stderr, "wrong number of arguments for -m: %s\n", _margins)
-		os.Exit(1)
+}
+
+// rowPositions returns the top y coordinate of every line fitting on the page,
+// a grown line not fitting anymore is left out.
+func rowPositions(cfg Config) []float64 {
+	ys := []float64{}
+	y := cfg.Margins[0]
+	for (y+cfg.rowHeight(len(ys))) < (cfg.canvas().Height-cfg.Margins[2]) && (cfg.Rows == 0 || len(ys) < cfg.Rows) {
+		y, ys = y+cfg.rowHeight(len(ys))+cfg.LineSpacing, append(ys, y)
+	}
+	return ys
+}
+
+func sum(values []float64) float64 {
+	s := 0.0
+	for _, v := range values {
+		s += v
+	}
+	return s
+}
+
+// drawVignette darkens the margins toward the page edges with rings of
+// decreasing opacity, the darkest ring reaches into the bleed.
+func drawVignette(pdf Canvas, paperSize PaperSize, margins []float64, intensity, bleed float64) {
+	const rings = 20
+	inset := func(i int, side int) float64 {
+		return margins[side] * float64(i) / rings
+	}
+	pdf.SetFillColor(0, 0, 0)
+	if bleed > 0 {
+		pdf.SetAlpha(intensity, "Normal")
+		pdf.Rect(-bleed, -bleed, paperSize.Width+2*bleed, bleed, "F")
+		pdf.Rect(-bleed, paperSize.Height, paperSize.Width+2*bleed, bleed, "F")
+		pdf.Rect(-bleed, 0, bleed, paperSize.Height, "F")
+		pdf.Rect(paperSize.Width, 0, bleed, paperSize.Height, "F")
+	}
+	for i := 0; i < rings; i++ {
+		pdf.SetAlpha(intensity*(1-float64(i)/rings), "Normal")
+		top, right, bottom, left := inset(i, 0), inset(i, 1), inset(i, 2), inset(i, 3)
+		nextTop, nextRight, nextBottom, nextLeft := inset(i+1, 0), inset(i+1, 1), inset(i+1, 2), inset(i+1, 3)
+		// top and bottom bands span the ring, left and right are between them
+		pdf.Rect(left, top, paperSize.Width-left-right, nextTop-top, "F")
+		pdf.Rect(left, paperSize.Height-nextBottom, paperSize.Width-left-right, nextBottom-bottom, "F")
+		pdf.Rect(left, nextTop, nextLeft-left, paperSize.Height-nextTop-nextBottom, "F")
+		pdf.Rect(paperSize.Width-nextRight, nextTop, nextRight-right, paperSize.Height-nextTop-nextBottom, "F")
 	}
+	pdf.SetAlpha(1, "Normal")
+}
+
+// GridPatterns are the patterns filling the page instead of the lines, see -grid.
+var GridPatterns = []string{"dots", "squares", "seyes", "tianzige", "mizige", "genko"}
+
+// drawDotGrid fills the area with a lattice of dots of the diameter starting
+// at its top left corner, no dot lies past the right or bottom edge. The dots
+// are the round ends of lines without length, a fraction of the size of
+// circles in a PDF.
+func drawDotGrid(pdf Canvas, x, y, width, height, spacing, diameter float64) {
+	// a little tolerance keeps the last dot on an edge hit exactly
+	const epsilon = 1e-9
+	pdf.SetLineWidth(diameter)
+	pdf.SetLineCapStyle("round")
+	for j := 0; float64(j)*spacing <= height+epsilon; j++ {
+		for i := 0; float64(i)*spacing <= width+epsilon; i++ {
+			pdf.Line(x+float64(i)*spacing, y+float64(j)*spacing, x+float64(i)*spacing, y+float64(j)*spacing)
+		}
+	}
+	pdf.SetLineCapStyle("butt")
+}
 
-	// Initialize the graphic context on a pdf document
-	pdf := gofpdf.New("P", "mm", paperSize, "")
+// drawSquareGrid rules the area into squares starting at its top left corner,
+// the lines end at the right and bottom edge cutting the last cells short.
+func drawSquareGrid(pdf Canvas, x, y, width, height, spacing, lineWidth float64) {
+	const epsilon = 1e-9
+	pdf.SetLineWidth(lineWidth)
+	for j := 0; float64(j)*spacing <= height+epsilon; j++ {
+		pdf.Line(x, y+float64(j)*spacing, x+width, y+float64(j)*spacing)
+	}
+	for i := 0; float64(i)*spacing <= width+epsilon; i++ {
+		pdf.Line(x+float64(i)*spacing, y, x+float64(i)*spacing, y+height)
+	}
+}
+
+// seyesFineLines is the number of fine lines between two main lines of the
+// Séyès ruling.
+const seyesFineLines = 3
+
+// drawSeyesGrid rules the area into squares like drawSquareGrid and adds fine
+// horizontal lines between the main lines.
+func drawSeyesGrid(pdf Canvas, x, y, width, height, spacing, lineWidth float64) {
+	const epsilon = 1e-9
+	fineSpacing := spacing / (seyesFineLines + 1)
+	pdf.SetLineWidth(lineWidth / 3)
+	for j := 0; float64(j)*fineSpacing <= height+epsilon; j++ {
+		if j%(seyesFineLines+1) != 0 {
+			pdf.Line(x, y+float64(j)*fineSpacing, x+width, y+float64(j)*fineSpacing)
+		}
+	}
+	drawSquareGrid(pdf, x, y, width, height, spacing, lineWidth)
+}
+
+// Binder holes after ISO 838: 80 mm apart, centered on the page edge. The
+// US 3-hole punch sets them 4 1/4 in apart.
+const (
+	holeSpacing   = 80.0
+	usHoleSpacing = 108.0
+	holeDistance  = 12.0 // of the hole centers from the page edge
+	holeRadius    = 3.0
+)
+
+// holePositions returns the y coordinates of the hole centers on a page of
+// the height, shifted by the offset. 3 holes are the US pattern.
+func holePositions(holes int, height, offset float64) []float64 {
+	spacing := holeSpacing
+	if holes == 3 {
+		spacing = usHoleSpacing
+	}
+	ys := []float64{}
+	for i := 0; i < holes; i++ {
+		ys = append(ys, height/2+offset+(float64(i)-float64(holes-1)/2)*spacing)
+	}
+	return ys
+}
+
+// drawHoles marks the binder holes with crossed circles centered at x.
+func drawHoles(pdf Canvas, x float64, holes int, height, offset float64) {
+	pdf.SetDrawColor(160, 160, 160)
+	pdf.SetLineWidth(0.1)
+	for _, y := range holePositions(holes, height, offset) {
+		pdf.Circle(x, y, holeRadius, "D")
+		pdf.Line(x-holeRadius, y, x+holeRadius, y)
+		pdf.Line(x, y-holeRadius, x, y+holeRadius)
+	}
+}
+
+// drawCharacterGrid rules as many whole cells as fit into the area for
+// writing CJK characters, with a dotted cross in every cell for tianzige and
+// the diagonals too for mizige.
+func drawCharacterGrid(pdf Canvas, x, y, width, height, size, lineWidth float64, pattern string) {
+	cols, rows := math.Floor(width/size), math.Floor(height/size)
+	pdf.SetLineWidth(lineWidth / 2)
+	setLineStyle(pdf, "dotted", lineWidth/2)
+	for i := 0.0; i < cols; i++ {
+		for j := 0.0; j < rows; j++ {
+			cx, cy := x+i*size, y+j*size
+			pdf.Line(cx, cy+size/2, cx+size, cy+size/2)
+			pdf.Line(cx+size/2, cy, cx+size/2, cy+size)
+			if pattern == "mizige" {
+				pdf.Line(cx, cy, cx+size, cy+size)
+				pdf.Line(cx+size, cy, cx, cy+size)
+			}
+		}
+	}
+	setLineStyle(pdf, "solid", lineWidth)
+	drawSquareGrid(pdf, x, y, cols*size, rows*size, size, lineWidth)
+}
+
+// genkoGap is the width of the narrow column between the columns of
+// squares of genkō yōshi, relative to the squares.
+const genkoGap = 0.25
+
+// drawGenko rules genkō yōshi: columns of squares top down with a narrow
+// column for furigana between them.
+func drawGenko(pdf Canvas, x, y, width, height, size, lineWidth float64) {
+	step := size * (1 + genkoGap)
+	// the last column needs no furigana column after it
+	cols, rows := math.Floor((width+size*genkoGap)/step), math.Floor(height/size)
+	pdf.SetLineWidth(lineWidth)
+	for i := 0.0; i < cols; i++ {
+		drawSquareGrid(pdf, x+i*step, y, size, rows*size, size, lineWidth)
+	}
+}
+
+// drawMarginLine draws the vertical margin line at the offset from the left
+// of the area.
+func drawMarginLine(pdf Canvas, x, y, height, offset, lineWidth float64, color Color) {
+	pdf.SetDrawColor(color.R, color.G, color.B)
+	pdf.SetLineWidth(lineWidth)
+	pdf.Line(x+offset, y, x+offset, y+height)
+}
+
+// staffProportions divide a music staff into the four spaces between its five
+// lines.
+var staffProportions = []float64{1, 1, 1, 1}
+
+// Layers are the parts of the lines in their default drawing order, see -order.
+var Layers = []string{"bg", "shade", "lines", "borders", "slants", "guides", "text"}
+
+// DrawAll draws all lines fitting on the current page of the canvas.
+func DrawAll(pdf Canvas, cfg Config) {
+	if cfg.Staff {
+		cfg = cfg.staff()
+	}
+	if cfg.Lines > 0 || cfg.Distribute {
+		cfg = cfg.fit()
+	}
+	width := cfg.canvas().Width - cfg.Margins[1] - cfg.Margins[3]
+	x := cfg.Margins[3]
+	rows := rowPositions(cfg)
+	if cfg.Grid != "" {
+		// a grid replaces the lines and everything aligned to them
+		rows = nil
+	}
+	if cfg.Cells {
+		// the lines end with the last whole cell
+		width = math.Floor(width/cfg.LineHeight) * cfg.LineHeight
+	}
+	lineDists := func(i int) []float64 {
+		// the gaps between the zones are taken from the line height
+		return ProportionsToLengths(cfg.Proportions, cfg.rowHeight(i)-sum(cfg.ZoneGaps))
+	}
+	layers := map[string]func(){
+		"bg": func() {
+			if cfg.Vignette > 0 {
+				drawVignette(pdf, cfg.canvas(), cfg.Margins, cfg.Vignette, cfg.Bleed)
+			}
+		},
+		"shade": func() {
+			if len(cfg.RowTints) > 0 {
+				for i, y := range rows {
+					// the tints take turns from line to line
+					if tint := cfg.RowTints[i%len(cfg.RowTints)]; tint != White {
+						pdf.SetFillColor(tint.R, tint.G, tint.B)
+						pdf.Rect(x, y, width, cfg.rowHeight(i), "F")
+					}
+				}
+				pdf.SetFillColor(0, 0, 0)
+			}
+			for i, y := range rows {
+				drawZoneColors(pdf, x, y, width, lineDists(i), cfg.ZoneGaps, cfg.ZoneColors, cfg.ZoneOpacity)
+			}
+		},
+		"lines": func() {
+			// the end dots are filled in the line color
+			pdf.SetDrawColor(cfg.LineColor.R, cfg.LineColor.G, cfg.LineColor.B)
+			pdf.SetFillColor(cfg.LineColor.R, cfg.LineColor.G, cfg.LineColor.B)
+			height := cfg.canvas().Height - cfg.Margins[0] - cfg.Margins[2]
+			switch cfg.Grid {
+			case "dots":
+				diameter := cfg.DotSize
+				if diameter == 0 {
+					diameter = 2 * cfg.LineWidth
+				}
+				drawDotGrid(pdf, x, cfg.Margins[0], width, height, cfg.GridSpacing, diameter)
+			case "squares":
+				drawSquareGrid(pdf, x, cfg.Margins[0], width, height, cfg.GridSpacing, cfg.LineWidth)
+				if cfg.GridMajor > 0 {
+					// the major lines are drawn over the minor ones
+					pdf.SetDrawColor(cfg.MajorColor.R, cfg.MajorColor.G, cfg.MajorColor.B)
+					drawSquareGrid(pdf, x, cfg.Margins[0], width, height, cfg.GridSpacing*float64(cfg.GridMajor), cfg.MajorWidth)
+				}
+			case "seyes":
+				drawSeyesGrid(pdf, x, cfg.Margins[0], width, height, cfg.GridSpacing, cfg.LineWidth)
+			case "tianzige", "mizige":
+				drawCharacterGrid(pdf, x, cfg.Margins[0], width, height, cfg.GridSpacing, cfg.LineWidth, cfg.Grid)
+			case "genko":
+				drawGenko(pdf, x, cfg.Margins[0], width, height, cfg.GridSpacing, cfg.LineWidth)
+			}
+			for i, y := range rows {
+				drawLineatur(pdf, x, y, cfg.rowHeight(i), width, lineDists(i), cfg.ZoneGaps, cfg.LineWidth, cfg.ZoneStyles, cfg.LineColors, cfg.LineWidths, cfg.EndDots, cfg.FadeRight, cfg.Hanging)
+			}
+			if len(cfg.SubLines) > 0 {
+				for i, y := range rows {
+					drawSubLines(pdf, x, y, width, lineDists(i), cfg.ZoneGaps, cfg.SubLines, cfg.LineWidth, cfg.FadeRight)
+				}
+			}
+			if cfg.Baseline {
+				// the bold baseline covers the line drawn in its place
+				pdf.SetDrawColor(cfg.BaselineColor.R, cfg.BaselineColor.G, cfg.BaselineColor.B)
+				if cfg.BaselineWidth > 0 {
+					pdf.SetLineWidth(cfg.BaselineWidth)
+				} else {
+					pdf.SetLineWidth(baselineWidth * cfg.LineWidth)
+				}
+				for i, y := range rows {
+					offset := baselineOffset(cfg.rowHeight(i), lineDists(i), cfg.ZoneGaps, cfg.BaselineLine)
+					drawHorizontal(pdf, x, y+offset, width, cfg.LineWidth, "solid", false, cfg.FadeRight)
+				}
+			}
+		},
+		"borders": func() {
+			pdf.SetDrawColor(cfg.LineColor.R, cfg.LineColor.G, cfg.LineColor.B)
+			for i, y := range rows {
+				drawBorders(pdf, x, y, cfg.rowHeight(i), width, lineDists(i), cfg.LineWidth, cfg.FadeRight)
+			}
+			if cfg.Staff && cfg.GrandStaff {
+				// the bar lines join the staves of a pair
+				for i := 0; i+1 < len(rows); i += 2 {
+					bottom := rows[i+1] + cfg.rowHeight(i+1)
+					pdf.Line(x, rows[i], x, bottom)
+					pdf.Line(x+width, rows[i], x+width, bottom)
+				}
+			}
+			if cfg.MarginLine > 0 {
+				offset := cfg.MarginLine
+				if cfg.Mirror {
+					offset = width - cfg.MarginLine
+				}
+				drawMarginLine(pdf, x, cfg.Margins[0], cfg.canvas().Height-cfg.Margins[0]-cfg.Margins[2], offset, cfg.LineWidth, cfg.MarginLineColor)
+			}
+			if cfg.Cells {
+				pdf.SetLineWidth(cfg.LineWidth)
+				for i, y := range rows {
+					for cell := cfg.LineHeight; cell < width-cfg.LineHeight/2; cell += cfg.LineHeight {
+						pdf.Line(x+cell, y, x+cell, y+cfg.rowHeight(i))
+					}
+				}
+			}
+			if cfg.CenterLine {
+				drawMarginLine(pdf, x, cfg.Margins[0], cfg.canvas().Height-cfg.Margins[0]-cfg.Margins[2], width/2, cfg.LineWidth, cfg.LineColor)
+			}
+		},
+		"slants": func() {
+			pdf.SetDrawColor(cfg.SlantColor.R, cfg.SlantColor.G, cfg.SlantColor.B)
+			pdf.SetLineWidth(cfg.LineWidth)
+			for _, block := range cfg.slantBlocks(rows) {
+				drawSlants(pdf, x, block[0], block[1], width, cfg.slants(), cfg.SlantSpacing, cfg.FadeRight)
+			}
+		},
+		"guides": func() {
+			// the first line is the warm-up line
+			if len(rows) > 0 && cfg.Warmup != "" {
+				offset, zoneHeight := xHeightZone(cfg.rowHeight(0), lineDists(0), cfg.ZoneGaps)
+				drawWarmup(pdf, x, rows[0]+offset, width, zoneHeight, cfg.Warmup, cfg.WarmupAmplitude, cfg.WarmupLength, cfg.LineWidth)
+			}
+			if cfg.Holes > 0 {
+				holesX := holeDistance
+				if cfg.Mirror != cfg.back {
+					holesX = cfg.canvas().Width - holeDistance
+				}
+				drawHoles(pdf, holesX, cfg.Holes, cfg.canvas().Height, cfg.HoleOffset)
+			}
+			if cfg.NibLadder {
+				pdf.SetFillColor(cfg.LineColor.R, cfg.LineColor.G, cfg.LineColor.B)
+				for i, y := range rows {
+					drawNibLadder(pdf, x, y, cfg.Proportions, lineDists(i), cfg.ZoneGaps)
+				}
+			}
+			if cfg.LoopGuides > 0 {
+				for i, y := range rows {
+					drawLoopGuides(pdf, x, y, cfg.rowHeight(i), width, cfg.LoopGuides, cfg.slants(), cfg.LineWidth)
+				}
+			}
+		},
+		"text": func() {
+			// the text to trace starts below the exemplar
+			first := 0
+			if cfg.Exemplar != "" && len(rows) > 0 {
+				drawLineText(pdf, cfg, cfg.Exemplar, "", exemplarColor, x, width, rows[:1], 0, lineDists)
+				first = 1
+			}
+			if cfg.TraceText != "" {
+				drawLineText(pdf, cfg, cfg.TraceText, cfg.TraceFont, cfg.TraceColor, x, width, rows, first, lineDists)
+			}
+			for i, y := range rows {
+				if cfg.UnitTicks {
+					drawUnitTicks(pdf, x, y, cfg.Proportions, lineDists(i), cfg.ZoneGaps)
+				}
+				if cfg.LineNumbers > 0 && (i+1)%cfg.LineNumbers == 0 {
+					baseline := y + baselineOffset(cfg.rowHeight(i), lineDists(i), cfg.ZoneGaps, 0)
+					drawLineNumber(pdf, x, width, baseline, i+1, cfg.LineNumbersRight)
+				}
+				if cfg.ZoneDims == "all" || (cfg.ZoneDims == "first" && i == 0) {
+					// labels go into the right margin
+					drawZoneDims(pdf, x+width+1, y, lineDists(i), cfg.ZoneGaps)
+				}
+			}
+		},
+	}
+	order := cfg.Order
+	if len(order) == 0 {
+		order = Layers
+	}
+	for _, layer := range order {
+		layers[layer]()
+	}
+	pdf.SetDrawColor(0, 0, 0)
+	pdf.SetFillColor(0, 0, 0)
+}
+
+// drawAllSlants draws only the slanted helper lines, aligned to the lines
+// drawn by DrawAll with the same configuration.
+func drawAllSlants(pdf Canvas, cfg Config) {
+	if cfg.Lines > 0 || cfg.Distribute {
+		cfg = cfg.fit()
+	}
+	width := cfg.canvas().Width - cfg.Margins[1] - cfg.Margins[3]
+	x := cfg.Margins[3]
+	pdf.SetDrawColor(cfg.SlantColor.R, cfg.SlantColor.G, cfg.SlantColor.B)
+	pdf.SetLineWidth(cfg.LineWidth)
+	for _, block := range cfg.slantBlocks(rowPositions(cfg)) {
+		drawSlants(pdf, x, block[0], block[1], width, cfg.slants(), cfg.SlantSpacing, cfg.FadeRight)
+	}
+	pdf.SetDrawColor(0, 0, 0)
+}
+
+// bookletOrder returns the content page (0-based) of the left and right half
+// of every sheet side of a saddle-stitched booklet with n pages, n is a
+// multiple of 4. The sides are in printing order: front, back, front, ...
+func bookletOrder(n int) [][2]int {
+	sides := [][2]int{}
+	for s := 0; s < n/4; s++ {
+		sides = append(sides, [2]int{n - 1 - 2*s, 2 * s})
+		sides = append(sides, [2]int{2*s + 1, n - 2 - 2*s})
+	}
+	return sides
+}
+
+// drawNup imposes the pages in order on as few sheets as hold them, cfg.Nup
+// per sheet side, the cells of the last sheet are filled with the first pages
+// again. Dashed lines mark the cuts.
+func drawNup(pdf Canvas, cfg Config, pages []func(pdf Canvas, cfg Config)) {
+	sheet, cell := cfg.sheet(), cfg.canvas()
+	cols := int(math.Round(sheet.Width / cell.Width))
+	for first := 0; first < len(pages); first += cfg.Nup {
+		pdf.AddPage()
+		for i := 0; i < cfg.Nup; i++ {
+			pdf.TransformBegin()
+			pdf.TransformTranslate(float64(i%cols)*cell.Width, float64(i/cols)*cell.Height)
+			pages[(first+i)%len(pages)](pdf, cfg)
+			pdf.TransformEnd()
+		}
+		pdf.SetLineWidth(0.1)
+		pdf.SetDrawColor(160, 160, 160)
+		pdf.SetDashPattern([]float64{3, 3}, 0)
+		pdf.Line(sheet.Width/2, 0, sheet.Width/2, sheet.Height)
+		if cfg.Nup == 4 {
+			pdf.Line(0, sheet.Height/2, sheet.Width, sheet.Height/2)
+		}
+		pdf.SetDashPattern([]float64{}, 0)
+		pdf.SetDrawColor(0, 0, 0)
+	}
+}
+
+// drawBooklet imposes the booklet pages two per sheet side in folding order,
+// cycling through the page contents, with a fold line in the middle.
+func drawBooklet(pdf Canvas, cfg Config, pages []func(pdf Canvas, cfg Config)) {
+	// round up to full sheets
+	n := (cfg.Booklet + 3) / 4 * 4
+	sheet, cell := cfg.sheet(), cfg.canvas()
+	for _, side := range bookletOrder(n) {
+		pdf.AddPage()
+		for i, page := range side {
+			pdf.TransformBegin()
+			pdf.TransformTranslate(float64(i)*cell.Width, 0)
+			pages[page%len(pages)](pdf, cfg)
+			pdf.TransformEnd()
+		}
+		pdf.SetLineWidth(0.1)
+		pdf.SetDrawColor(160, 160, 160)
+		pdf.SetDashPattern([]float64{3, 3}, 0)
+		pdf.Line(sheet.Width/2, 0, sheet.Width/2, sheet.Height)
+		pdf.SetDashPattern([]float64{}, 0)
+		pdf.SetDrawColor(0, 0, 0)
+	}
+}
+
+// compareLabelHeight is the space in mm above each block of -compare for its label.
+const compareLabelHeight = 5.0
+
+// drawCompare divides the page height among the blocks of proportions to
+// compare and draws each labeled block with its proportions.
+func drawCompare(pdf Canvas, cfg Config, draw func(pdf Canvas, cfg Config)) {
+	top, bottom := cfg.Margins[0], cfg.canvas().Height-cfg.Margins[2]
+	blockHeight := (bottom - top) / float64(len(cfg.Compare))
+	for i, block := range cfg.Compare {
+		blockTop := top + float64(i)*blockHeight
+		// the text of the blocks changes the font
+		pdf.SetFont("Helvetica", "", 8)
+		pdf.Text(cfg.Margins[3], blockTop+compareLabelHeight-1.5, block.Label)
+		blockCfg := cfg
+		blockCfg.Margins = []float64{blockTop + compareLabelHeight, cfg.Margins[1], cfg.canvas().Height - blockTop - blockHeight, cfg.Margins[3]}
+		blockCfg.Proportions = block.Proportions
+		blockCfg.Vignette = 0
+		draw(pdf, blockCfg)
+	}
+	if cfg.Vignette > 0 {
+		drawVignette(pdf, cfg.canvas(), cfg.Margins, cfg.Vignette, cfg.Bleed)
+	}
+}
+
+// drawColumns splits the width between the margins into columns with the
+// gutter between them and draws the page in every column. The binder holes and
+// the margin line belong to the page and are only drawn with the first column,
+// the mirrored margin line with the last.
+func drawColumns(pdf Canvas, cfg Config, draw func(pdf Canvas, cfg Config)) {
+	width := cfg.canvas().Width - cfg.Margins[1] - cfg.Margins[3]
+	columnWidth := (width - float64(cfg.Columns-1)*cfg.ColumnGutter) / float64(cfg.Columns)
+	for i := 0; i < cfg.Columns; i++ {
+		left := cfg.Margins[3] + float64(i)*(columnWidth+cfg.ColumnGutter)
+		columnCfg := cfg
+		columnCfg.Margins = []float64{cfg.Margins[0], cfg.canvas().Width - left - columnWidth, cfg.Margins[2], left}
+		columnCfg.Vignette = 0
+		if i > 0 {
+			columnCfg.Holes = 0
+		}
+		if (!cfg.Mirror && i > 0) || (cfg.Mirror && i < cfg.Columns-1) {
+			columnCfg.MarginLine = 0
+		}
+		draw(pdf, columnCfg)
+	}
+	if cfg.Vignette > 0 {
+		drawVignette(pdf, cfg.canvas(), cfg.Margins, cfg.Vignette, cfg.Bleed)
+	}
+}
+
+// Fonts are the core fonts for the title, see -font.
+var Fonts = []string{"Helvetica", "Times", "Courier"}
+
+// titleGap is the space in mm between the title and the lines below it.
+const titleGap = 2.0
+
+// headerLineGap is the space in mm above the labels of the header fields to
+// write in, headerFieldGap the space between two fields.
+const (
+	headerLineGap  = 4.0
+	headerFieldGap = 5.0
+)
+
+// drawHeader writes the labels of the header fields side by side at the top
+// margin, each followed by a line to write on up to the next field, and draws
+// the page below them.
+func drawHeader(pdf Canvas, cfg Config, draw func(pdf Canvas, cfg Config)) {
+	x := cfg.Margins[3]
+	width := cfg.canvas().Width - cfg.Margins[1] - cfg.Margins[3]
+	y := cfg.Margins[0] + headerLineGap + cfg.TitleSize*25.4/72
+	fieldWidth := width / float64(len(cfg.Header))
+	pdf.SetFont(cfg.Font, "", cfg.TitleSize)
+	pdf.SetDrawColor(cfg.LineColor.R, cfg.LineColor.G, cfg.LineColor.B)
+	pdf.SetLineWidth(cfg.LineWidth)
+	for i, label := range cfg.Header {
+		fieldX, end := x+float64(i)*fieldWidth, x+float64(i+1)*fieldWidth
+		if i < len(cfg.Header)-1 {
+			end -= headerFieldGap
+		}
+		start := fieldX
+		if label != "" {
+			pdf.Text(fieldX, y, label)
+			start += pdf.GetStringWidth(label) + 1
+		}
+		if start < end {
+			pdf.Line(start, y, end, y)
+		}
+	}
+	pageCfg := cfg
+	pageCfg.Margins = []float64{cfg.Margins[0] + cfg.headerHeight(), cfg.Margins[1], cfg.Margins[2], cfg.Margins[3]}
+	pageCfg.Vignette = 0
+	draw(pdf, pageCfg)
+	if cfg.Vignette > 0 {
+		drawVignette(pdf, cfg.canvas(), cfg.Margins, cfg.Vignette, cfg.Bleed)
+	}
+}
+
+// drawVertical draws the page turned a quarter clockwise, the lines run down
+// the page as columns from the right to the left.
+func drawVertical(pdf Canvas, cfg Config, draw func(pdf Canvas, cfg Config)) {
+	width := cfg.canvas().Width
+	pdf.TransformBegin()
+	// the top left corner of the turned page is the top right one
+	pdf.TransformRotate(-90, width/2, width/2)
+	draw(pdf, cfg.vertical())
+	pdf.TransformEnd()
+}
+
+// drawTitle writes the title at the top margin and draws the page below it.
+func drawTitle(pdf Canvas, cfg Config, draw func(pdf Canvas, cfg Config)) {
+	pdf.SetFont(cfg.Font, "", cfg.TitleSize)
+	pdf.Text(cfg.Margins[3], cfg.Margins[0]+cfg.TitleSize*25.4/72, cfg.Title)
+	pageCfg := cfg
+	pageCfg.Margins = []float64{cfg.Margins[0] + cfg.titleHeight(), cfg.Margins[1], cfg.Margins[2], cfg.Margins[3]}
+	pageCfg.Vignette = 0
+	draw(pdf, pageCfg)
+	if cfg.Vignette > 0 {
+		drawVignette(pdf, cfg.canvas(), cfg.Margins, cfg.Vignette, cfg.Bleed)
+	}
+}
+
+// drawWatermark draws the image and the text of the watermark behind the
+// page, centered and turned around the center. The text is set as wide as
+// the image.
+func drawWatermark(pdf Canvas, cfg Config, draw func(pdf Canvas, cfg Config)) {
+	size := cfg.canvas()
+	x, y := size.Width/2, size.Height/2
+	if len(cfg.WatermarkCenter) == 2 {
+		x, y = cfg.WatermarkCenter[0], cfg.WatermarkCenter[1]
+	}
+	width := cfg.WatermarkWidth
+	if width == 0 {
+		width = size.Width * 2 / 3
+	}
+	if cfg.WatermarkOpacity > 0 {
+		pdf.SetAlpha(cfg.WatermarkOpacity, "Normal")
+	}
+	pdf.TransformBegin()
+	pdf.TransformRotate(cfg.WatermarkAngle, x, y)
+	if cfg.WatermarkImage != "" {
+		height := imageHeight(cfg.WatermarkImage, width)
+		pdf.Image(cfg.WatermarkImage, x-width/2, y-height/2, width, height, false, "", 0, "")
+	}
+	if cfg.Watermark != "" {
+		pdf.SetFont(cfg.Font, "", 100)
+		fontSize := 100 * width / pdf.GetStringWidth(cfg.Watermark)
+		pdf.SetFont(cfg.Font, "", fontSize)
+		// the cap height is about 0.7 of the font size
+		pdf.Text(x-width/2, y+0.7*fontSize*25.4/72/2, cfg.Watermark)
+	}
+	pdf.TransformEnd()
+	pdf.SetAlpha(1, "Normal")
+	draw(pdf, cfg)
+}
+
+// cornellGap is the space in mm between the notes and the cue column or the
+// summary of the Cornell layout.
+const cornellGap = 3.0
+
+// drawCornell divides the page into the cue column on the left (the right if
+// mirrored), the summary box at the bottom and the notes, which are drawn into
+// what's left.
+func drawCornell(pdf Canvas, cfg Config, draw func(pdf Canvas, cfg Config)) {
+	cue, summary := cfg.Cornell[0], cfg.Cornell[1]
+	x, top := cfg.Margins[3], cfg.Margins[0]
+	width := cfg.canvas().Width - cfg.Margins[1] - cfg.Margins[3]
+	bottom := cfg.canvas().Height - cfg.Margins[2]
+	pdf.SetDrawColor(cfg.LineColor.R, cfg.LineColor.G, cfg.LineColor.B)
+	pdf.SetLineWidth(cfg.LineWidth)
+	if cue > 0 && cfg.Mirror {
+		pdf.Line(x+width-cue, top, x+width-cue, bottom-summary)
+	} else if cue > 0 {
+		pdf.Line(x+cue, top, x+cue, bottom-summary)
+	}
+	if summary > 0 {
+		pdf.Rect(x, bottom-summary, width, summary, "D")
+	}
+	pageCfg := cfg
+	pageCfg.Margins = []float64{cfg.Margins[0], cfg.Margins[1], cfg.Margins[2], cfg.Margins[3]}
+	if cue > 0 && cfg.Mirror {
+		pageCfg.Margins[1] += cue + cornellGap
+	} else if cue > 0 {
+		pageCfg.Margins[3] += cue + cornellGap
+	}
+	if summary > 0 {
+		pageCfg.Margins[2] += summary + cornellGap
+	}
+	pageCfg.Vignette = 0
+	draw(pdf, pageCfg)
+	if cfg.Vignette > 0 {
+		drawVignette(pdf, cfg.canvas(), cfg.Margins, cfg.Vignette, cfg.Bleed)
+	}
+}
+
+// drawCenterCross draws a crosshair at the center of the page.
+func drawCenterCross(pdf Canvas, paperSize PaperSize, length float64, color Color) {
+	cx, cy := paperSize.Width/2, paperSize.Height/2
+	pdf.SetDrawColor(color.R, color.G, color.B)
+	pdf.SetLineWidth(0.1)
+	pdf.Line(cx-length/2, cy, cx+length/2, cy)
+	pdf.Line(cx, cy-length/2, cx, cy+length/2)
+	pdf.SetDrawColor(0, 0, 0)
+}
+
+// pdfaMetadata returns the XMP metadata of the document information in the
+// style of PDF/A. It doesn't claim a PDF/A conformance level, the files lack
+// the output intent and the catalog reference to the metadata.
+func pdfaMetadata(title, author, subject, producer, keywords string, created time.Time) []byte {
+	creator := ""
+	if author != "" {
+		creator = "\n<dc:creator><rdf:Seq><rdf:li>" + html.EscapeString(author) + "</rdf:li></rdf:Seq></dc:creator>"
+	}
+	return []byte(fmt.Sprintf(`<?xpacket begin="`+"\ufeff"+`" id="W5M0MpCehiHzreSzNTczkc9d"?>
+<x:xmpmeta xmlns:x="adobe:ns:meta/">
+<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+<rdf:Description rdf:about="" xmlns:dc="http://purl.org/dc/elements/1.1/">
+<dc:title><rdf:Alt><rdf:li xml:lang="x-default">%s</rdf:li></rdf:Alt></dc:title>%s
+<dc:description><rdf:Alt><rdf:li xml:lang="x-default">%s</rdf:li></rdf:Alt></dc:description>
+</rdf:Description>
+<rdf:Description rdf:about="" xmlns:pdf="http://ns.adobe.com/pdf/1.3/">
+<pdf:Producer>%s</pdf:Producer>
+<pdf:Keywords>%s</pdf:Keywords>
+</rdf:Description>
+<rdf:Description rdf:about="" xmlns:xmp="http://ns.adobe.com/xap/1.0/">
+<xmp:CreateDate>%s</xmp:CreateDate>
+</rdf:Description>
+</rdf:RDF>
+</x:xmpmeta>
+<?xpacket end="w"?>`, html.EscapeString(title), creator, html.EscapeString(subject), producer, keywords, created.Format(time.RFC3339)))
+}
+
+// drawSpread draws the page content of two pages side by side on the left
+// and the right page, the gutter in the middle stays unruled.
+func drawSpread(pdf Canvas, cfg Config, draw func(pdf Canvas, cfg Config)) {
+	paperSize, gutter := cfg.PaperSize, cfg.SpreadGutter
+	for page := 0; page < 2; page++ {
+		pdf.AddPage()
+		// each page has half of the gutter at the fold
+		clipX := 0.0
+		if page == 1 {
+			clipX = gutter / 2
+		}
+		pdf.ClipRect(clipX, 0, paperSize.Width-gutter/2, paperSize.Height, false)
+		pdf.TransformBegin()
+		pdf.TransformTranslate(-float64(page)*paperSize.Width, 0)
+		draw(pdf, cfg)
+		pdf.TransformEnd()
+		pdf.ClipEnd()
+	}
+}
+
+// drawPoster tiles the page content of a poster across as many pages of the
+// paper size as needed. Neighbouring tiles overlap, registration marks in the
+// overlapping parts are used to glue the tiles together.
+func drawPoster(pdf Canvas, cfg Config, draw func(pdf Canvas, cfg Config)) {
+	paperSize, posterSize, overlap := cfg.PaperSize, cfg.Poster, cfg.PosterOverlap
+	stepX := paperSize.Width - overlap
+	stepY := paperSize.Height - overlap
+	cols := int(math.Ceil((posterSize.Width - overlap) / stepX))
+	rows := int(math.Ceil((posterSize.Height - overlap) / stepY))
+	pdf.SetFont("Helvetica", "", 8)
+	for row := 0; row < rows; row++ {
+		for col := 0; col < cols; col++ {
+			pdf.AddPage()
+			pdf.TransformBegin()
+			pdf.TransformTranslate(-float64(col)*stepX, -float64(row)*stepY)
+			draw(pdf, cfg)
+			// the marks are at the same poster position on all tiles sharing them
+			pdf.SetLineWidth(0.2)
+			for i := 0; i <= cols; i++ {
+				for j := 0; j <= rows; j++ {
+					mx := float64(i)*stepX + overlap/2
+					my := float64(j)*stepY + overlap/2
+					pdf.Circle(mx, my, overlap/6, "D")
+					pdf.Line(mx-overlap/3, my, mx+overlap/3, my)
+					pdf.Line(mx, my-overlap/3, mx, my+overlap/3)
+				}
+			}
+			pdf.TransformEnd()
+			if cfg.PDFA {
+				// PDF/A only allows embedded fonts
+				continue
+			}
+			// tile name: row letter and column number
+			pdf.Text(overlap+1, paperSize.Height-1, fmt.Sprintf("%c%d (%d x %d)", 'A'+row, col+1, rows, cols))
+		}
+	}
+}
+
+// deterministicDate is the creation date of deterministic documents.
+var deterministicDate = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// NewPDF returns a PDF document with the page size of the configuration and
+// the metadata identifying the version and configuration it was made with.
+// The title is that of the page or the description of the ruling, the
+// subject always the description.
+func NewPDF(cfg Config) *gofpdf.Fpdf {
+	orientation, size := pdfPageSize(cfg.media())
+	pdf := gofpdf.NewCustom(&gofpdf.InitType{OrientationStr: orientation, UnitStr: "mm", Size: size})
+	setUpPDF(pdf, cfg)
+	return pdf
+}
+
+// pdfDocument is the part of the documents of gofpdf and go-pdf/fpdf setting
+// up a new document.
+type pdfDocument interface {
+	SetMargins(left, top, right float64)
+	SetAutoPageBreak(auto bool, margin float64)
+	SetPageBox(t string, x, y, wd, ht float64)
+	SetCatalogSort(flag bool)
+	SetTitle(titleStr string, isUTF8 bool)
+	SetSubject(subjectStr string, isUTF8 bool)
+	SetAuthor(authorStr string, isUTF8 bool)
+	SetProducer(producerStr string, isUTF8 bool)
+	SetKeywords(keywordsStr string, isUTF8 bool)
+	SetCreationDate(tm time.Time)
+	SetModificationDate(tm time.Time)
+	SetXmpMetadata(xmpStream []byte)
+}
+
+// setUpPDF sets the margins, the page boxes and the metadata of a new PDF
+// document.
+func setUpPDF(pdf pdfDocument, cfg Config) {
 	pdf.SetMargins(0, 0, 0)
 	pdf.SetAutoPageBreak(false, 0)
-	pdf.AddPage()
-	drawAllLineatur(pdf, PaperSizes[paperSize], margins, float64(lineHeight), float64(lineSpacing), proportions, slants, lineWidth)
-	pdf.OutputFileAndClose(filename)
+	if offset := cfg.trimOffset(); offset > 0 {
+		// print shops cut the media to the trim box
+		sheet := cfg.sheet()
+		pdf.SetPageBox("trim", offset, offset, sheet.Width, sheet.Height)
+		if cfg.Bleed > 0 {
+			pdf.SetPageBox("bleed", offset-cfg.Bleed, offset-cfg.Bleed, sheet.Width+2*cfg.Bleed, sheet.Height+2*cfg.Bleed)
+		}
+	}
+	// the sheet can be traced back to the version and configuration
+	producer := "lineatur " + Version
+	created := time.Now()
+	if cfg.Deterministic {
+		// the same configuration gives the same bytes with every version
+		producer, created = "lineatur", deterministicDate
+		pdf.SetCatalogSort(true)
+	}
+	keywords := producer + " config " + cfg.hash()
+	title, subject := cfg.Title, cfg.description()
+	if title == "" {
+		title = subject
+	}
+	pdf.SetTitle(title, true)
+	pdf.SetSubject(subject, true)
+	pdf.SetAuthor(cfg.Author, true)
+	pdf.SetProducer(producer, false)
+	pdf.SetKeywords(keywords, false)
+	pdf.SetCreationDate(created)
+	pdf.SetModificationDate(created)
+	if cfg.PDFA {
+		// the metadata has to agree with the document information
+		pdf.SetXmpMetadata(pdfaMetadata(title, cfg.Author, subject, producer, keywords, created))
+	}
+}
+
+// footerSize is the font size in points of the footer, footerDistance the
+// distance in mm of its baseline from the bottom edge of the sheet.
+const (
+	footerSize     = 6.0
+	footerDistance = 5.0
+)
+
+// drawFooter writes the page number and the description of the ruling at the
+// bottom of the sheet.
+func drawFooter(pdf Canvas, cfg Config, page int) {
+	pdf.SetFont("Helvetica", "", footerSize)
+	pdf.SetAlpha(1, "Normal")
+	pdf.Text(cfg.Margins[3], cfg.sheet().Height-footerDistance, fmt.Sprintf("%d   %s", page, cfg.description()))
+}
+
+// qrModule is the smallest module of a QR code in mm phones still read,
+// qrQuietZone the modules of the light border readers need around the code
+// and qrDefaultSize the largest side of the code fitted into the margin.
+const (
+	qrModule      = 0.25
+	qrQuietZone   = 4
+	qrDefaultSize = 12.0
+)
+
+// drawQR draws the QR code of the configuration at the right margin,
+// centered in the bottom margin.
+func drawQR(pdf Canvas, cfg Config) {
+	modules, err := qrModules(cfg.QR)
+	if err != nil {
+		return
+	}
+	size := cfg.qrSize(len(modules))
+	module := size / float64(len(modules))
+	// a narrow right margin would cut into the quiet zone
+	x := cfg.sheet().Width - math.Max(cfg.Margins[1], qrQuietZone*module) - size
+	y := cfg.sheet().Height - (cfg.Margins[2]+size)/2
+	pdf.SetAlpha(1, "Normal")
+	pdf.SetFillColor(0, 0, 0)
+	for row, dark := range modules {
+		// a run of dark modules is one rectangle
+		for start := 0; start < len(dark); start++ {
+			if !dark[start] {
+				continue
+			}
+			end := start
+			for end < len(dark) && dark[end] {
+				end++
+			}
+			pdf.Rect(x+float64(start)*module, y+float64(row)*module, float64(end-start)*module, module, "F")
+			start = end
+		}
+	}
+}
+
+// DrawPages adds all pages of the configuration to the canvas: the lines,
+// the slants overlay, the compared blocks, the title, the poster tiles, the
+// spread or the booklet sheets.
+func DrawPages(pdf Canvas, cfg Config) {
+	current := cfg
+	if cfg.CenterCross > 0 || cfg.Footer || cfg.QR != "" {
+		setFooter(pdf, &current)
+	}
+	pages := pageFuncs(cfg)
+	if cfg.Booklet > 0 {
+		drawBooklet(pdf, cfg, pages)
+		return
+	}
+	if cfg.Nup > 1 {
+		drawNup(pdf, cfg, pages)
+		return
+	}
+	for i, draw := range pages {
+		if cfg.Poster != (PaperSize{}) {
+			drawPoster(pdf, cfg, draw)
+		} else if cfg.Spread {
+			drawSpread(pdf, cfg, draw)
+		} else if cfg.Duplex && i%2 == 1 {
+			// the binding of the back is on the right, the footer of the
+			// previous page is drawn by AddPage
+			pdf.AddPage()
+			current = cfg.backPage()
+			draw(pdf, current)
+		} else {
+			pdf.AddPage()
+			current = cfg
+			draw(pdf, cfg)
+		}
+	}
+}
+
+// setFooter draws the center cross, the footer and the QR code of the
+// configuration on every page, current is the configuration of the page being
+// finished.
+func setFooter(pdf Canvas, current *Config) {
+	page := 0
+	pdf.SetFooterFunc(func() {
+		page++
+		if current.CenterCross > 0 {
+			drawCenterCross(pdf, current.sheet(), current.CenterCross, current.CenterCrossColor)
+		}
+		if current.Footer {
+			drawFooter(pdf, *current, page)
+		}
+		if current.QR != "" {
+			drawQR(pdf, *current)
+		}
+	})
+}
+
+// pageFuncs returns the functions drawing the pages of the configuration: the
+// lines, the slants overlay, the compared blocks, the columns, the Cornell
+// layout, the header and the title, repeated for a pad.
+func pageFuncs(cfg Config) []func(pdf Canvas, cfg Config) {
+	if cfg.Calibrate {
+		// the page measures the printer, it has no lines
+		return []func(pdf Canvas, cfg Config){drawCalibration}
+	}
+	pages := []func(pdf Canvas, cfg Config){}
+	if cfg.SlantsOverlay {
+		pages = append(pages, func(pdf Canvas, cfg Config) {
+			cfg.Slants = nil
+			DrawAll(pdf, cfg)
+		})
+		// the overlay page only carries the slanted helper lines
+		pages = append(pages, drawAllSlants)
+	} else {
+		pages = append(pages, DrawAll)
+	}
+	if cfg.Vertical {
+		for i, draw := range pages {
+			draw := draw
+			pages[i] = func(pdf Canvas, cfg Config) {
+				drawVertical(pdf, cfg, draw)
+			}
+		}
+	}
+	if cfg.Columns > 1 {
+		for i, draw := range pages {
+			draw := draw
+			pages[i] = func(pdf Canvas, cfg Config) {
+				drawColumns(pdf, cfg, draw)
+			}
+		}
+	}
+	if len(cfg.Compare) > 0 {
+		for i, draw := range pages {
+			draw := draw
+			pages[i] = func(pdf Canvas, cfg Config) {
+				drawCompare(pdf, cfg, draw)
+			}
+		}
+	}
+	if cfg.Cornell != nil {
+		for i, draw := range pages {
+			draw := draw
+			pages[i] = func(pdf Canvas, cfg Config) {
+				drawCornell(pdf, cfg, draw)
+			}
+		}
+	}
+	if len(cfg.Header) > 0 {
+		for i, draw := range pages {
+			draw := draw
+			pages[i] = func(pdf Canvas, cfg Config) {
+				drawHeader(pdf, cfg, draw)
+			}
+		}
+	}
+	if cfg.Title != "" {
+		for i, draw := range pages {
+			draw := draw
+			pages[i] = func(pdf Canvas, cfg Config) {
+				drawTitle(pdf, cfg, draw)
+			}
+		}
+	}
+	if cfg.Watermark != "" || cfg.WatermarkImage != "" {
+		for i, draw := range pages {
+			draw := draw
+			pages[i] = func(pdf Canvas, cfg Config) {
+				drawWatermark(pdf, cfg, draw)
+			}
+		}
+	}
+	// the pages are repeated for a pad
+	n := len(pages)
+	for i := 1; i < cfg.Pages; i++ {
+		pages = append(pages, pages[:n]...)
+	}
+	return pages
+}
+
+// Render draws the pages of the configuration on a canvas of the format
+// (pdf, svg, dxf or png) and returns the function writing them. The DXF units
+// and layer are only used for dxf, the resolution in dots per inch for png.
+func Render(cfg Config, format, dxfUnits, dxfLayer string, dpi float64) func(w io.Writer) error {
+	return render(cfg, format, dxfUnits, dxfLayer, dpi, func(pdf Canvas) {
+		DrawPages(pdf, cfg)
+	})
+}
+
+// render draws on a canvas of the format for the sheet of the configuration.
+// With a bleed or crop marks the sheet is drawn on the larger media. The
+// lines are batched into paths. A drawing stopped at the limits returns their
+// error when written.
+func render(cfg Config, format, dxfUnits, dxfLayer string, dpi float64, draw func(pdf Canvas)) func(w io.Writer) error {
+	if cfg.trimOffset() > 0 || len(cfg.Scale) == 2 {
+		drawSheet := draw
+		draw = func(pdf Canvas) {
+			t := &trimmed{Canvas: pdf, cfg: cfg}
+			drawSheet(t)
+			t.end()
+		}
+	}
+	drawLines := draw
+	draw = func(pdf Canvas) {
+		b := newBatched(pdf)
+		drawLines(b)
+		b.flush()
+	}
+	if cfg.Limits != (Limits{}) {
+		drawUnlimited := draw
+		draw = func(pdf Canvas) {
+			drawUnlimited(&limited{Canvas: pdf, limits: cfg.Limits})
+		}
+	}
+	var canvas Canvas
+	var output func(w io.Writer) error
+	switch format {
+	case "png":
+		png := NewPNG(cfg.media(), dpi)
+		canvas, output = png, png.Output
+	case "svg":
+		svg := NewSVG(cfg.media())
+		canvas, output = svg, svg.Output
+	case "dxf":
+		dxf := NewDXF(cfg.media(), dxfUnits, dxfLayer)
+		canvas, output = dxf, dxf.Output
+	default:
+		canvas, output = newPDFCanvas(cfg)
+	}
+	if err := stop(func() { draw(canvas) }); err != nil {
+		return func(w io.Writer) error { return err }
+	}
+	return func(w io.Writer) error {
+		// the PDF draws the footer of the last page when it is written
+		var err error
+		if stopErr := stop(func() { err = output(w) }); stopErr != nil {
+			return stopErr
+		}
+		return err
+	}
+}
+
+// Render validates the configuration and writes all its pages as PDF to w,
+// the shortcut for programs that only need the document.
+func (cfg Config) Render(w io.Writer) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	return Render(cfg, "pdf", "", "", 0)(w)
 }
@@ -0,0 +1,27 @@
+//go:build fpdf
+
+package lineatur
+
+import (
+	"io"
+
+	"github.com/go-pdf/fpdf"
+)
+
+// PDFBackend is the library writing the PDF output, gofpdf in builds without
+// the tag fpdf.
+const PDFBackend = "go-pdf/fpdf"
+
+// NewFPDF is NewPDF on go-pdf/fpdf, the maintained fork of gofpdf.
+func NewFPDF(cfg Config) *fpdf.Fpdf {
+	orientation, size := pdfPageSize(cfg.media())
+	pdf := fpdf.NewCustom(&fpdf.InitType{OrientationStr: orientation, UnitStr: "mm", Size: fpdf.SizeType{Wd: size.Wd, Ht: size.Ht}})
+	setUpPDF(pdf, cfg)
+	return pdf
+}
+
+// newPDFCanvas returns the document of NewFPDF and the function writing it.
+func newPDFCanvas(cfg Config) (Canvas, func(w io.Writer) error) {
+	pdf := NewFPDF(cfg)
+	return pdf, pdf.Output
+}
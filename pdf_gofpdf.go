@@ -0,0 +1,15 @@
+//go:build !fpdf
+
+package lineatur
+
+import "io"
+
+// PDFBackend is the library writing the PDF output, go-pdf/fpdf in builds
+// with the tag fpdf.
+const PDFBackend = "gofpdf"
+
+// newPDFCanvas returns the document of NewPDF and the function writing it.
+func newPDFCanvas(cfg Config) (Canvas, func(w io.Writer) error) {
+	pdf := NewPDF(cfg)
+	return pdf, pdf.Output
+}
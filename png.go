@@ -0,0 +1,520 @@
+package lineatur
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/jung-kurt/gofpdf"
+	xdraw "golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/f64"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/image/vector"
+)
+
+// PNG rasterizes the pages into a single PNG image at a resolution in dots
+// per inch. Pages after the first are put below each other, separated by a
+// transparent gap like in the SVG.
+type PNG struct {
+	paperSize  PaperSize
+	scale      float64 // pixels per mm
+	pages      []*image.RGBA
+	footer     func()
+	lineWidth  float64
+	lineCap    string
+	dashArray  []float64
+	dashPhase  float64
+	drawColor  color.NRGBA
+	fillColor  color.NRGBA
+	alpha      float64
+	path       [][][2]float64 // sub-paths started by MoveTo
+	transforms transforms     // per TransformBegin
+	clips      []image.Rectangle
+	raster     *vector.Rasterizer
+	family     string
+	fonts      map[string]*opentype.Font // TrueType fonts by family
+	faces      map[faceKey]font.Face
+	textColor  color.NRGBA
+	// measures the text with the same core fonts as gofpdf
+	metrics *gofpdf.Fpdf
+	err     error // of the first image or font that couldn't be read
+}
+
+// NewPNG returns a PNG with pages of the paper size at the resolution.
+func NewPNG(paperSize PaperSize, dpi float64) *PNG {
+	metrics := gofpdf.New("P", "mm", "A4", "")
+	metrics.SetFont("Helvetica", "", 12)
+	return &PNG{
+		paperSize: paperSize,
+		scale:     dpi / 25.4,
+		lineWidth: 0.2,
+		drawColor: color.NRGBA{0, 0, 0, 255},
+		fillColor: color.NRGBA{0, 0, 0, 255},
+		alpha:     1,
+		raster:    vector.NewRasterizer(0, 0),
+		fonts:     map[string]*opentype.Font{},
+		faces:     map[faceKey]font.Face{},
+		textColor: color.NRGBA{0, 0, 0, 255},
+		metrics:   metrics,
+	}
+}
+
+// Pixels returns the number of pixels of all PNG pages of the configuration
+// at the resolution, e.g. to refuse a sheet too large to draw in memory.
+func (cfg Config) Pixels(dpi float64) float64 {
+	pages := 1.0
+	if cfg.SlantsOverlay && !cfg.Calibrate {
+		pages = 2
+	}
+	pages *= math.Max(1, float64(cfg.Pages))
+	switch {
+	case cfg.Booklet > 0:
+		pages = float64((cfg.Booklet + 3) / 4 * 2)
+	case cfg.Nup > 1:
+		pages = math.Ceil(pages / float64(cfg.Nup))
+	case cfg.Poster != (PaperSize{}):
+		stepX := cfg.PaperSize.Width - cfg.PosterOverlap
+		stepY := cfg.PaperSize.Height - cfg.PosterOverlap
+		if stepX <= 0 || stepY <= 0 {
+			return math.Inf(1)
+		}
+		pages *= math.Ceil((cfg.Poster.Width-cfg.PosterOverlap)/stepX) * math.Ceil((cfg.Poster.Height-cfg.PosterOverlap)/stepY)
+	case cfg.Spread:
+		pages *= 2
+	}
+	media, scale := cfg.media(), dpi/25.4
+	return pages * math.Round(media.Width*scale) * math.Round(media.Height*scale)
+}
+
+func (p *PNG) page() *image.RGBA {
+	return p.pages[len(p.pages)-1]
+}
+
+// pixel converts page coordinates in mm to pixels of the page image.
+func (p *PNG) pixel(x, y float64) (float32, float32) {
+	x, y = p.transforms.apply(x, y)
+	return float32(x * p.scale), float32(y * p.scale)
+}
+
+// clip returns the part of the page that may be drawn on.
+func (p *PNG) clip() image.Rectangle {
+	r := p.page().Bounds()
+	for _, c := range p.clips {
+		r = r.Intersect(c)
+	}
+	return r
+}
+
+// fill rasterizes the closed polygons in page coordinates with the color,
+// the rasterizer only covers their bounding box.
+func (p *PNG) fill(polygons [][][2]float64, c color.NRGBA) {
+	points := [][][2]float32{}
+	box := image.Rectangle{}
+	for _, polygon := range polygons {
+		pixels := [][2]float32{}
+		for _, point := range polygon {
+			x, y := p.pixel(point[0], point[1])
+			pixels = append(pixels, [2]float32{x, y})
+			box = box.Union(image.Rect(int(math.Floor(float64(x))), int(math.Floor(float64(y))), int(math.Ceil(float64(x)))+1, int(math.Ceil(float64(y)))+1))
+		}
+		points = append(points, pixels)
+	}
+	r := box.Intersect(p.clip())
+	if r.Empty() {
+		return
+	}
+	p.raster.Reset(r.Dx(), r.Dy())
+	for _, pixels := range points {
+		if len(pixels) < 3 {
+			continue
+		}
+		origin := [2]float32{float32(r.Min.X), float32(r.Min.Y)}
+		p.raster.MoveTo(pixels[0][0]-origin[0], pixels[0][1]-origin[1])
+		for _, px := range pixels[1:] {
+			p.raster.LineTo(px[0]-origin[0], px[1]-origin[1])
+		}
+		p.raster.ClosePath()
+	}
+	c.A = uint8(math.Round(float64(c.A) * p.alpha))
+	p.raster.Draw(p.page(), r, image.NewUniform(c), image.Point{})
+}
+
+// dashes cuts the polyline into the pieces drawn by the dash pattern.
+func (p *PNG) dashes(points [][2]float64) [][][2]float64 {
+	if len(p.dashArray) == 0 {
+		return [][][2]float64{points}
+	}
+	period := sum(p.dashArray)
+	if period <= 0 {
+		return [][][2]float64{points}
+	}
+	// the position in the pattern and whether it is a dash
+	dash, left, on := 0, p.dashArray[0], true
+	for phase := math.Mod(p.dashPhase, period); phase > 0; {
+		step := math.Min(phase, left)
+		phase, left = phase-step, left-step
+		if left == 0 {
+			dash = (dash + 1) % len(p.dashArray)
+			left, on = p.dashArray[dash], !on
+		}
+	}
+	pieces := [][][2]float64{}
+	piece := [][2]float64{}
+	if on {
+		piece = append(piece, points[0])
+	}
+	for i := 1; i < len(points); i++ {
+		x, y := points[i-1][0], points[i-1][1]
+		dx, dy := points[i][0]-x, points[i][1]-y
+		length := math.Hypot(dx, dy)
+		for done := 0.0; done < length; {
+			step := math.Min(length-done, left)
+			done, left = done+step, left-step
+			point := [2]float64{x + dx*done/length, y + dy*done/length}
+			if on {
+				piece = append(piece, point)
+			}
+			if left == 0 {
+				if on {
+					pieces = append(pieces, piece)
+				}
+				dash = (dash + 1) % len(p.dashArray)
+				left, on = p.dashArray[dash], !on
+				piece = [][2]float64{}
+				if on {
+					piece = append(piece, point)
+				}
+			}
+		}
+	}
+	if on && len(piece) > 1 {
+		pieces = append(pieces, piece)
+	}
+	return pieces
+}
+
+// stroke draws the polyline as a quadrilateral per segment in the line width.
+func (p *PNG) stroke(points [][2]float64) {
+	quads := [][][2]float64{}
+	for _, piece := range p.dashes(points) {
+		for i := 1; i < len(piece); i++ {
+			x1, y1, x2, y2 := piece[i-1][0], piece[i-1][1], piece[i][0], piece[i][1]
+			length := math.Hypot(x2-x1, y2-y1)
+			if length == 0 {
+				continue
+			}
+			nx, ny := -(y2-y1)/length*p.lineWidth/2, (x2-x1)/length*p.lineWidth/2
+			quads = append(quads, [][2]float64{{x1 + nx, y1 + ny}, {x2 + nx, y2 + ny}, {x2 - nx, y2 - ny}, {x1 - nx, y1 - ny}})
+		}
+	}
+	p.fill(quads, p.drawColor)
+	if p.lineCap == "round" {
+		// a line without length is a dot
+		caps := [][][2]float64{}
+		for _, piece := range p.dashes(points) {
+			if len(piece) == 0 {
+				continue
+			}
+			first, last := piece[0], piece[len(piece)-1]
+			caps = append(caps, ellipse(first[0], first[1], p.lineWidth/2, p.lineWidth/2, 0))
+			if last != first {
+				caps = append(caps, ellipse(last[0], last[1], p.lineWidth/2, p.lineWidth/2, 0))
+			}
+		}
+		p.fill(caps, p.drawColor)
+	}
+}
+
+// paint fills and draws the closed polygon for a gofpdf style string: D
+// draws, F fills, DF and FD do both.
+func (p *PNG) paint(polygon [][2]float64, styleStr string) {
+	style := strings.ToUpper(styleStr)
+	if style == "F" || style == "DF" || style == "FD" {
+		p.fill([][][2]float64{polygon}, p.fillColor)
+	}
+	if style != "F" {
+		p.stroke(append(polygon, polygon[0]))
+	}
+}
+
+func (p *PNG) AddPage() {
+	if len(p.pages) > 0 && p.footer != nil {
+		p.footer()
+	}
+	width := int(math.Round(p.paperSize.Width * p.scale))
+	height := int(math.Round(p.paperSize.Height * p.scale))
+	page := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(page, page.Bounds(), image.White, image.Point{}, draw.Src)
+	p.pages = append(p.pages, page)
+}
+
+func (p *PNG) SetFooterFunc(fnc func()) {
+	p.footer = fnc
+}
+
+func (p *PNG) SetLineWidth(width float64) {
+	p.lineWidth = width
+}
+
+// SetLineCapStyle sets the ends of the lines, only round ends are drawn.
+func (p *PNG) SetLineCapStyle(styleStr string) {
+	p.lineCap = styleStr
+}
+
+func (p *PNG) SetDashPattern(dashArray []float64, dashPhase float64) {
+	p.dashArray, p.dashPhase = dashArray, dashPhase
+}
+
+func (p *PNG) SetDrawColor(r, g, b int) {
+	p.drawColor = color.NRGBA{uint8(r), uint8(g), uint8(b), 255}
+}
+
+func (p *PNG) SetFillColor(r, g, b int) {
+	p.fillColor = color.NRGBA{uint8(r), uint8(g), uint8(b), 255}
+}
+
+// SetAlpha sets the opacity, only the normal blend mode is supported.
+func (p *PNG) SetAlpha(alpha float64, blendModeStr string) {
+	p.alpha = alpha
+}
+
+func (p *PNG) MoveTo(x, y float64) {
+	p.path = append(p.path, [][2]float64{{x, y}})
+}
+
+func (p *PNG) LineTo(x, y float64) {
+	if len(p.path) == 0 {
+		p.MoveTo(x, y)
+		return
+	}
+	p.path[len(p.path)-1] = append(p.path[len(p.path)-1], [2]float64{x, y})
+}
+
+func (p *PNG) DrawPath(styleStr string) {
+	style := strings.ToUpper(styleStr)
+	if style == "F" || style == "DF" || style == "FD" {
+		p.fill(p.path, p.fillColor)
+	}
+	if style != "F" {
+		for _, points := range p.path {
+			p.stroke(points)
+		}
+	}
+	p.path = nil
+}
+
+func (p *PNG) Line(x1, y1, x2, y2 float64) {
+	p.stroke([][2]float64{{x1, y1}, {x2, y2}})
+}
+
+func (p *PNG) Rect(x, y, w, h float64, styleStr string) {
+	p.paint([][2]float64{{x, y}, {x + w, y}, {x + w, y + h}, {x, y + h}}, styleStr)
+}
+
+func (p *PNG) Circle(x, y, r float64, styleStr string) {
+	p.Ellipse(x, y, r, r, 0, styleStr)
+}
+
+// Ellipse is rotated counter-clockwise like in gofpdf and approximated with
+// lines.
+func (p *PNG) Ellipse(x, y, rx, ry, degRotate float64, styleStr string) {
+	p.paint(ellipse(x, y, rx, ry, degRotate), styleStr)
+}
+
+// ellipse returns the polygon approximating the ellipse.
+func ellipse(x, y, rx, ry, degRotate float64) [][2]float64 {
+	const steps = 72
+	rotation := degRotate * math.Pi / 180
+	polygon := [][2]float64{}
+	for i := 0; i < steps; i++ {
+		t := 2 * math.Pi * float64(i) / steps
+		ex, ey := rx*math.Cos(t), ry*math.Sin(t)
+		// counter-clockwise on the page, whose y axis points down
+		polygon = append(polygon, [2]float64{x + ex*math.Cos(rotation) + ey*math.Sin(rotation), y - ex*math.Sin(rotation) + ey*math.Cos(rotation)})
+	}
+	return polygon
+}
+
+// AddUTF8FontFromBytes adds the TrueType font, the style is ignored.
+func (p *PNG) AddUTF8FontFromBytes(familyStr, styleStr string, utf8Bytes []byte) {
+	if _, ok := p.fonts[familyStr]; ok {
+		return
+	}
+	f, err := opentype.Parse(utf8Bytes)
+	if err != nil {
+		if p.err == nil {
+			p.err = err
+		}
+		return
+	}
+	p.fonts[familyStr] = f
+	p.metrics.AddUTF8FontFromBytes(familyStr, styleStr, utf8Bytes)
+}
+
+// SetFont measures with the core font, the text is written in the Go fonts:
+// Go Mono for Courier, Go Regular for the others. The fonts of
+// AddUTF8FontFromBytes are written in themselves.
+func (p *PNG) SetFont(familyStr, styleStr string, size float64) {
+	p.metrics.SetFont(familyStr, styleStr, size)
+	p.family = familyStr
+}
+
+func (p *PNG) GetFontSize() (ptSize, unitSize float64) {
+	return p.metrics.GetFontSize()
+}
+
+func (p *PNG) GetStringWidth(s string) float64 {
+	return p.metrics.GetStringWidth(s)
+}
+
+// faceKey identifies a font face of the PNG.
+type faceKey struct {
+	family string
+	size   float64
+}
+
+// face returns the font face of the current font and size, made once.
+func (p *PNG) face() font.Face {
+	size, _ := p.metrics.GetFontSize()
+	key := faceKey{p.family, size}
+	if face, ok := p.faces[key]; ok {
+		return face
+	}
+	f, ok := p.fonts[p.family]
+	if !ok {
+		fontFile := goregular.TTF
+		if p.family == "Courier" {
+			fontFile = gomono.TTF
+		}
+		f, _ = opentype.Parse(fontFile)
+	}
+	var face font.Face
+	if f != nil {
+		face, _ = opentype.NewFace(f, &opentype.FaceOptions{Size: size, DPI: p.scale * 25.4, Hinting: font.HintingNone})
+	}
+	p.faces[key] = face
+	return face
+}
+
+func (p *PNG) SetTextColor(r, g, b int) {
+	p.textColor = color.NRGBA{uint8(r), uint8(g), uint8(b), 255}
+}
+
+// Text is drawn upright first and turned onto the page when it is rotated
+// or scaled.
+func (p *PNG) Text(x, y float64, txtStr string) {
+	face := p.face()
+	if face == nil {
+		return
+	}
+	dst := p.page().SubImage(p.clip()).(*image.RGBA)
+	c := p.textColor
+	c.A = uint8(math.Round(255 * p.alpha))
+	src := image.NewUniform(c)
+	m := p.transforms.matrix()
+	if m.moves() {
+		px, py := p.pixel(x, y)
+		d := font.Drawer{
+			Dst:  dst,
+			Src:  src,
+			Face: face,
+			Dot:  fixed.Point26_6{X: fixed.Int26_6(px * 64), Y: fixed.Int26_6(py * 64)},
+		}
+		d.DrawString(txtStr)
+		return
+	}
+	// the pixels of the upright text are relative to the start of the baseline
+	bounds, _ := font.BoundString(face, txtStr)
+	r := image.Rect(bounds.Min.X.Floor()-1, bounds.Min.Y.Floor()-1, bounds.Max.X.Ceil()+1, bounds.Max.Y.Ceil()+1)
+	text := image.NewRGBA(r)
+	d := font.Drawer{Dst: text, Src: src, Face: face}
+	d.DrawString(txtStr)
+	px, py := m.apply(x, y)
+	xdraw.BiLinear.Transform(dst, f64.Aff3{m[0], m[2], px * p.scale, m[1], m[3], py * p.scale}, text, r, xdraw.Over, nil)
+}
+
+func (p *PNG) TransformBegin() {
+	p.transforms = append(p.transforms, identity)
+}
+
+func (p *PNG) TransformTranslate(tx, ty float64) {
+	p.transforms.add(translation(tx, ty))
+}
+
+func (p *PNG) TransformRotate(angle, x, y float64) {
+	p.transforms.add(rotation(angle, x, y))
+}
+
+func (p *PNG) TransformScale(scaleWd, scaleHt, x, y float64) {
+	p.transforms.add(scaling(scaleWd, scaleHt, x, y))
+}
+
+func (p *PNG) TransformEnd() {
+	p.transforms = p.transforms[:len(p.transforms)-1]
+}
+
+// ClipRect clips to the bounding box of the rectangle if it is rotated.
+func (p *PNG) ClipRect(x, y, w, h float64, outline bool) {
+	if outline {
+		p.Rect(x, y, w, h, "D")
+	}
+	x, y, w, h = p.transforms.box(x, y, w, h)
+	x1, y1, x2, y2 := x*p.scale, y*p.scale, (x+w)*p.scale, (y+h)*p.scale
+	p.clips = append(p.clips, image.Rect(int(math.Floor(x1)), int(math.Floor(y1)), int(math.Ceil(x2)), int(math.Ceil(y2))))
+}
+
+func (p *PNG) ClipEnd() {
+	p.clips = p.clips[:len(p.clips)-1]
+}
+
+// Image draws a JPEG, PNG or GIF file, flow and links are ignored.
+func (p *PNG) Image(imageNameStr string, x, y, w, h float64, flow bool, tp string, link int, linkStr string) {
+	_, img, _, w, h, err := readImage(imageNameStr, w, h)
+	if err != nil {
+		if p.err == nil {
+			p.err = err
+		}
+		return
+	}
+	// the pixels of the image are scaled to the size, moved and turned
+	b := img.Bounds()
+	sx, sy := w/float64(b.Dx()), h/float64(b.Dy())
+	m := p.transforms.matrix()
+	px, py := m.apply(x-float64(b.Min.X)*sx, y-float64(b.Min.Y)*sy)
+	s2d := f64.Aff3{m[0] * sx * p.scale, m[2] * sy * p.scale, px * p.scale, m[1] * sx * p.scale, m[3] * sy * p.scale, py * p.scale}
+	opts := &xdraw.Options{SrcMask: image.NewUniform(color.Alpha16{uint16(math.Round(0xffff * p.alpha))})}
+	xdraw.BiLinear.Transform(p.page().SubImage(p.clip()).(*image.RGBA), s2d, img, b, xdraw.Over, opts)
+}
+
+// Output writes the PNG image.
+func (p *PNG) Output(w io.Writer) error {
+	if p.footer != nil && len(p.pages) > 0 {
+		p.footer()
+		p.footer = nil
+	}
+	if p.err != nil {
+		return p.err
+	}
+	if err := p.metrics.Error(); err != nil {
+		return err
+	}
+	if len(p.pages) == 1 {
+		return png.Encode(w, p.pages[0])
+	}
+	width := int(math.Round(p.paperSize.Width * p.scale))
+	height := int(math.Round(p.paperSize.Height * p.scale))
+	gap := int(math.Round(pageGap * p.scale))
+	sheet := image.NewRGBA(image.Rect(0, 0, width, int(math.Max(0, float64(len(p.pages)*(height+gap)-gap)))))
+	for i, page := range p.pages {
+		draw.Draw(sheet, page.Bounds().Add(image.Pt(0, i*(height+gap))), page, image.Point{}, draw.Src)
+	}
+	return png.Encode(w, sheet)
+}
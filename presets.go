@@ -0,0 +1,63 @@
+package lineatur
+
+import "sort"
+
+// Preset sets proportions, slanted helper lines, zone colors and line height
+// for a script at once, or a grid instead. The angles are measured from the baseline like
+// for -s.
+type Preset struct {
+	Proportions []float64
+	Slants      []float64 // angle and number per line, nil for none
+	ZoneColors  []Color   // one per zone, white zones aren't tinted
+	LineHeight  float64   // 0 keeps the line height
+	LineSpacing float64   // 0 keeps the line spacing
+	Grid        string    // grid pattern instead of the lines, empty for none
+	GridSpacing float64
+	MarginLine  float64 // offset of the margin line, 0 for none
+	CenterLine  bool
+	RowTints    []Color // background colors of the lines in turn, nil for none
+	Baseline    bool
+}
+
+// White zones are not tinted.
+var White = Color{255, 255, 255}
+
+// light blue tint for the x-height zone
+var xHeightTint = Color{225, 235, 250}
+
+// cream tint, with the light blue one for lines taking turns
+var creamTint = Color{255, 248, 220}
+
+// Presets are the scripts from the header comment and common calligraphy
+// hands, the letterform ratios are in nib widths.
+var Presets = map[string]Preset{
+	"suetterlin":  Preset{Proportions: []float64{1, 1, 1}, LineHeight: 12},
+	"offenbacher": Preset{Proportions: []float64{2, 3, 2}, Slants: []float64{75, 10}, LineHeight: 14},
+	"lateinisch":  Preset{Proportions: []float64{3, 4, 3}, LineHeight: 10},
+	"kurrent":     Preset{Proportions: []float64{2, 1, 2}, Slants: []float64{60, 10}, LineHeight: 15},
+	"copperplate": Preset{Proportions: []float64{3, 2, 3}, Slants: []float64{55, 10}, LineHeight: 16},
+	// italic: 5 nib widths x-height, about 4 for ascenders and descenders, 5°-7° slant
+	"italic": Preset{Proportions: []float64{4, 5, 4}, Slants: []float64{83, 10}, ZoneColors: []Color{White, xHeightTint, White}, LineHeight: 13},
+	// gothic textura: 5 nib widths x-height, short ascenders and descenders, upright
+	"gothic": Preset{Proportions: []float64{2, 5, 2}, Slants: []float64{90, 20}, ZoneColors: []Color{White, xHeightTint, White}, LineHeight: 9},
+	// uncial: 4 nib widths x-height, hardly any ascenders and descenders, upright
+	"uncial": Preset{Proportions: []float64{1, 4, 1}, Slants: []float64{90, 10}, ZoneColors: []Color{White, xHeightTint, White}, LineHeight: 6},
+	// French school ruling: squares of 8 mm with three fine lines in between and a margin line
+	"seyes": Preset{Grid: "seyes", GridSpacing: 8, MarginLine: 32},
+	// Gregg shorthand pads: single rules 11/32 in apart, two columns
+	"gregg": Preset{LineHeight: 4.35, LineSpacing: 4.35, CenterLine: true},
+	// Pitman shorthand: pairs of lines for the strokes above, on and through the line, two columns
+	"pitman": Preset{Proportions: []float64{1}, LineHeight: 4, LineSpacing: 6, CenterLine: true},
+	// readers with dyslexia: wide lines and gaps on pale tints taking turns, a bold baseline
+	"dyslexia": Preset{Proportions: []float64{1, 1, 1}, LineHeight: 12, LineSpacing: 12, RowTints: []Color{creamTint, xHeightTint}, Baseline: true},
+}
+
+// PresetNames returns the names of the presets, sorted.
+func PresetNames() []string {
+	names := []string{}
+	for name := range Presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,140 @@
+package lineatur
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// The matrices are those of another encoder for the same text at level M,
+// the dark modules are #.
+var (
+	qrVersion1 = `
+#######..##.#.#######
+#.....#.##.#..#.....#
+#.###.#.#####.#.###.#
+#.###.#.#.....#.###.#
+#.###.#.....#.#.###.#
+#.....#..#..#.#.....#
+#######.#.#.#.#######
+........##..#........
+#.....#.#....##..###.
+.###.#....##..##.....
+##..#.#.#.#.#.#..###.
+###..#....###..#.##..
+#.#..####.#..#..##...
+........##.#...###..#
+#######..##.#......#.
+#.....#.....#.#.###..
+#.###.#..#....#....##
+#.###.#...#..######..
+#.###.#..##.#.#.#.###
+#.....#.....##.#.##..
+#######.#..#..##...#.`
+	qrVersion7 = `
+#######.##.##.#.#........#.#.####...#.#######
+#.....#.#.#.####....##.....#.##.#..#..#.....#
+#.###.#...#.##.##..#..#..#.....##..#..#.###.#
+#.###.#.#.###.....#.#.#.##.#.###...##.#.###.#
+#.###.#..#####.####.#######.#...#####.#.###.#
+#.....#...###.##.####...#.#.#.#.......#.....#
+#######.#.#.#.#.#.#.#.#.#.#.#.#.#.#.#.#######
+........#..#.########...#.###.......#........
+#.##.###...#.##.##########.#####..#.#.#..#.##
+.##.......###...#.##.#.#.#..####.#.###......#
+##.#..#.#....##..#.####..#.##..#..##.#...#.##
+.#.#.#...##..#.##..#.##.##.#...###.#.#.....#.
+##..#.#.#..##.#.#.##.##.#....###...#.....#.##
+.####.....##....###.##..#.##....#..#..#...#..
+..#..###.####..##...####.###.###.#.########..
+.#..#..##.##.##...##....#.#.#######.#...####.
+...##.#####..#...#.##.##.###...###.##..#.###.
+.....#.#.#.##.###.#..#.##..#.#...##..#.##.###
+#.#.#.####.###.#######..#.##.#.#..#...#...##.
+####.#.###..##.##.#.##....###...#..#.##.#....
+#.#######.###...#...#######.###..##.#####.#..
+#####...#...##.#....#...#....##.#...#...#...#
+#..##.#.##.#..##.#..#.#.##.####...#.#.#.#..##
+..#.#...#.#.######.##...##......##..#...##.#.
+#..#######.######.#.#####.....#....######..##
+##...#.##..##.#.#..#..#.#####..###.####...#..
+##..#.###.##.#.#####.######.###..#.##..#.#...
+..####.#.#...#.##...#..#############.##...#.#
+#.##.###.###..##.#..#..####..#.##..####.#.###
+#.#.....#.#..###...###......##....#.##.######
+...##.#..##..#####.#.###.###...#####.#...#...
+#.#..#.#.#.......#.#......#.#....#..##.#....#
+..###.###.##...###...##...###.....#...#..###.
+..####.#...#.#.###########....#.#....#...##.#
+....#.#...##...####..#.##.....#.#.#.####...##
+.####..####......######...##.#..##.####.##...
+#..##.#..#..###.#...######.#.##.....#####..##
+........#.#..#....###...#####..###..#...#.#..
+#######.#.##.######.#.#.#.#.###.....#.#.#.#..
+#.....#.#.#..#..#...#...##.##.#.##.##...####.
+#.###.#..####...##..########.####..#########.
+#.###.#.##.#.#..#.###.##...###.#.#####......#
+#.###.#.###..##..##.##.####.#..##.#.#....###.
+#.....#...#..#....##....#.#.#......##.#..#..#
+#######.##.###.####..##...#.##.#..#..######..`
+)
+
+func TestQRModules(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{text: "lineatur -lh=8", want: qrVersion1},
+		{text: "lineatur -preset=copperplate -lh=17 -ls=8 -pages=2 -s=55:10 -footer -title='Practice sheet' -header=Name:Date -qr=true", want: qrVersion7},
+	}
+	for _, test := range tests {
+		modules, err := qrModules(test.text)
+		if err != nil {
+			t.Fatalf("qrModules(%q) failed: %v", test.text, err)
+		}
+		rows := []string{}
+		for _, row := range modules {
+			s := []byte(strings.Repeat(".", len(row)))
+			for x, dark := range row {
+				if dark {
+					s[x] = '#'
+				}
+			}
+			rows = append(rows, string(s))
+		}
+		if got := strings.Join(rows, "\n"); got != strings.TrimPrefix(test.want, "\n") {
+			t.Errorf("qrModules(%q) =\n%s\nwant%s", test.text, got, test.want)
+		}
+	}
+}
+
+func TestQRVersion(t *testing.T) {
+	// the capacities of the versions in bytes at level M
+	tests := []struct {
+		length  int
+		version int // 0 = too long
+	}{
+		{1, 1}, {14, 1}, {15, 2}, {26, 2}, {27, 3}, {106, 6}, {107, 7}, {122, 7}, {123, 8},
+		{180, 9}, {181, 10}, {213, 10}, {2331, 40}, {2332, 0},
+	}
+	for _, test := range tests {
+		modules, err := qrModules(strings.Repeat("a", test.length))
+		switch {
+		case test.version == 0 && err == nil:
+			t.Errorf("%d bytes got version %d, want an error", test.length, (len(modules)-17)/4)
+		case test.version != 0 && err != nil:
+			t.Errorf("%d bytes failed: %v", test.length, err)
+		case test.version != 0 && len(modules) != 4*test.version+17:
+			t.Errorf("%d bytes got version %d, want %d", test.length, (len(modules)-17)/4, test.version)
+		}
+	}
+}
+
+func TestQRRemainder(t *testing.T) {
+	// HELLO WORLD at 1-M
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	want := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+	if got := qrRemainder(data, qrDivisor(len(want))); !reflect.DeepEqual(got, want) {
+		t.Errorf("qrRemainder = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,308 @@
+package lineatur
+
+import (
+	"encoding/base64"
+	"fmt"
+	"html"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+// pageGap is the gap in mm between the pages of a multi page SVG.
+const pageGap = 10.0
+
+// SVG draws the pages into a single SVG document with mm user units. Pages
+// after the first are put below each other, separated by a gap.
+type SVG struct {
+	paperSize  PaperSize
+	pages      []*strings.Builder
+	footer     func()
+	lineWidth  float64
+	dashArray  string
+	lineCap    string
+	drawColor  string
+	fillColor  string
+	alpha      float64
+	path       strings.Builder
+	transforms []int // groups opened per TransformBegin
+	clips      int   // clip paths defined so far, for unique ids
+	fontFamily string
+	fonts      map[string][]byte // TrueType fonts by family, embedded in the document
+	textColor  string
+	// measures the text with the same core fonts as gofpdf
+	metrics *gofpdf.Fpdf
+	err     error // of the first image that couldn't be read
+}
+
+// NewSVG returns an SVG with pages of the paper size.
+func NewSVG(paperSize PaperSize) *SVG {
+	metrics := gofpdf.New("P", "mm", "A4", "")
+	metrics.SetFont("Helvetica", "", 12)
+	return &SVG{
+		paperSize:  paperSize,
+		lineWidth:  0.2,
+		drawColor:  "rgb(0,0,0)",
+		fillColor:  "rgb(0,0,0)",
+		alpha:      1,
+		fontFamily: svgFontFamilies["Helvetica"],
+		fonts:      map[string][]byte{},
+		textColor:  "rgb(0,0,0)",
+		metrics:    metrics,
+	}
+}
+
+func (s *SVG) page() *strings.Builder {
+	return s.pages[len(s.pages)-1]
+}
+
+func (s *SVG) AddPage() {
+	if len(s.pages) > 0 && s.footer != nil {
+		s.footer()
+	}
+	s.pages = append(s.pages, &strings.Builder{})
+}
+
+func (s *SVG) SetFooterFunc(fnc func()) {
+	s.footer = fnc
+}
+
+func (s *SVG) SetLineWidth(width float64) {
+	s.lineWidth = width
+}
+
+// SetLineCapStyle sets the ends of the lines, butt, round or square.
+func (s *SVG) SetLineCapStyle(styleStr string) {
+	s.lineCap = ""
+	if styleStr == "round" || styleStr == "square" {
+		s.lineCap = fmt.Sprintf(` stroke-linecap="%s"`, styleStr)
+	}
+}
+
+func (s *SVG) SetDashPattern(dashArray []float64, dashPhase float64) {
+	s.dashArray = ""
+	if len(dashArray) > 0 {
+		dashes := []string{}
+		for _, d := range dashArray {
+			dashes = append(dashes, fmt.Sprintf("%.3f", d))
+		}
+		s.dashArray = fmt.Sprintf(` stroke-dasharray="%s" stroke-dashoffset="%.3f"`, strings.Join(dashes, " "), dashPhase)
+	}
+}
+
+func (s *SVG) SetDrawColor(r, g, b int) {
+	s.drawColor = fmt.Sprintf("rgb(%d,%d,%d)", r, g, b)
+}
+
+func (s *SVG) SetFillColor(r, g, b int) {
+	s.fillColor = fmt.Sprintf("rgb(%d,%d,%d)", r, g, b)
+}
+
+// SetAlpha sets the opacity, only the normal blend mode is supported.
+func (s *SVG) SetAlpha(alpha float64, blendModeStr string) {
+	s.alpha = alpha
+}
+
+func (s *SVG) MoveTo(x, y float64) {
+	fmt.Fprintf(&s.path, "M%.3f %.3f ", x, y)
+}
+
+func (s *SVG) LineTo(x, y float64) {
+	fmt.Fprintf(&s.path, "L%.3f %.3f ", x, y)
+}
+
+// style returns the presentation attributes for a gofpdf style string:
+// D draws, F fills, DF and FD do both.
+func (s *SVG) style(styleStr string) string {
+	fill, stroke := "none", "none"
+	switch strings.ToUpper(styleStr) {
+	case "F":
+		fill = s.fillColor
+	case "DF", "FD":
+		fill, stroke = s.fillColor, s.drawColor
+	default:
+		stroke = s.drawColor
+	}
+	opacity := ""
+	if s.alpha < 1 {
+		opacity = fmt.Sprintf(` opacity="%.3f"`, s.alpha)
+	}
+	if stroke == "none" {
+		return fmt.Sprintf(`fill="%s"%s`, fill, opacity)
+	}
+	return fmt.Sprintf(`fill="%s" stroke="%s" stroke-width="%.3f"%s%s%s`, fill, stroke, s.lineWidth, s.lineCap, s.dashArray, opacity)
+}
+
+func (s *SVG) DrawPath(styleStr string) {
+	fmt.Fprintf(s.page(), "<path d=\"%s\" %s/>\n", strings.TrimSpace(s.path.String()), s.style(styleStr))
+	s.path.Reset()
+}
+
+func (s *SVG) Line(x1, y1, x2, y2 float64) {
+	fmt.Fprintf(s.page(), "<line x1=\"%.3f\" y1=\"%.3f\" x2=\"%.3f\" y2=\"%.3f\" %s/>\n", x1, y1, x2, y2, s.style("D"))
+}
+
+func (s *SVG) Rect(x, y, w, h float64, styleStr string) {
+	fmt.Fprintf(s.page(), "<rect x=\"%.3f\" y=\"%.3f\" width=\"%.3f\" height=\"%.3f\" %s/>\n", x, y, w, h, s.style(styleStr))
+}
+
+func (s *SVG) Circle(x, y, r float64, styleStr string) {
+	fmt.Fprintf(s.page(), "<circle cx=\"%.3f\" cy=\"%.3f\" r=\"%.3f\" %s/>\n", x, y, r, s.style(styleStr))
+}
+
+// Ellipse is rotated counter-clockwise like in gofpdf, SVG turns clockwise.
+func (s *SVG) Ellipse(x, y, rx, ry, degRotate float64, styleStr string) {
+	fmt.Fprintf(s.page(), "<ellipse cx=\"%.3f\" cy=\"%.3f\" rx=\"%.3f\" ry=\"%.3f\" transform=\"rotate(%.3f %.3f %.3f)\" %s/>\n", x, y, rx, ry, -degRotate, x, y, s.style(styleStr))
+}
+
+// svgFontFamilies are the CSS font families of the core fonts.
+var svgFontFamilies = map[string]string{
+	"Helvetica": "Helvetica, Arial, sans-serif",
+	"Times":     "Times, 'Times New Roman', serif",
+	"Courier":   "Courier, 'Courier New', monospace",
+}
+
+// AddUTF8FontFromBytes embeds the TrueType font, the style is ignored.
+func (s *SVG) AddUTF8FontFromBytes(familyStr, styleStr string, utf8Bytes []byte) {
+	if _, ok := s.fonts[familyStr]; ok {
+		return
+	}
+	s.metrics.AddUTF8FontFromBytes(familyStr, styleStr, utf8Bytes)
+	s.fonts[familyStr] = utf8Bytes
+}
+
+func (s *SVG) SetFont(familyStr, styleStr string, size float64) {
+	s.metrics.SetFont(familyStr, styleStr, size)
+	if family, ok := svgFontFamilies[familyStr]; ok {
+		s.fontFamily = family
+	} else if _, ok := s.fonts[familyStr]; ok {
+		s.fontFamily = "'" + familyStr + "'"
+	}
+}
+
+func (s *SVG) GetFontSize() (ptSize, unitSize float64) {
+	return s.metrics.GetFontSize()
+}
+
+func (s *SVG) GetStringWidth(str string) float64 {
+	return s.metrics.GetStringWidth(str)
+}
+
+func (s *SVG) SetTextColor(r, g, b int) {
+	s.textColor = fmt.Sprintf("rgb(%d,%d,%d)", r, g, b)
+}
+
+func (s *SVG) Text(x, y float64, txtStr string) {
+	_, size := s.metrics.GetFontSize()
+	opacity := ""
+	if s.alpha < 1 {
+		opacity = fmt.Sprintf(` opacity="%.3f"`, s.alpha)
+	}
+	fmt.Fprintf(s.page(), "<text x=\"%.3f\" y=\"%.3f\" font-family=\"%s\" font-size=\"%.3f\" fill=\"%s\"%s>%s</text>\n", x, y, s.fontFamily, size, s.textColor, opacity, html.EscapeString(txtStr))
+}
+
+func (s *SVG) TransformBegin() {
+	s.transforms = append(s.transforms, 0)
+}
+
+func (s *SVG) TransformTranslate(tx, ty float64) {
+	fmt.Fprintf(s.page(), "<g transform=\"translate(%.3f %.3f)\">\n", tx, ty)
+	s.transforms[len(s.transforms)-1]++
+}
+
+// TransformRotate turns counter-clockwise like in gofpdf, SVG turns
+// clockwise.
+func (s *SVG) TransformRotate(angle, x, y float64) {
+	fmt.Fprintf(s.page(), "<g transform=\"rotate(%.3f %.3f %.3f)\">\n", -angle, x, y)
+	s.transforms[len(s.transforms)-1]++
+}
+
+func (s *SVG) TransformScale(scaleWd, scaleHt, x, y float64) {
+	sx, sy := scaleWd/100, scaleHt/100
+	fmt.Fprintf(s.page(), "<g transform=\"matrix(%.6f 0 0 %.6f %.3f %.3f)\">\n", sx, sy, x-x*sx, y-y*sy)
+	s.transforms[len(s.transforms)-1]++
+}
+
+func (s *SVG) TransformEnd() {
+	n := s.transforms[len(s.transforms)-1]
+	s.transforms = s.transforms[:len(s.transforms)-1]
+	s.page().WriteString(strings.Repeat("</g>\n", n))
+}
+
+func (s *SVG) ClipRect(x, y, w, h float64, outline bool) {
+	if outline {
+		s.Rect(x, y, w, h, "D")
+	}
+	s.clips++
+	fmt.Fprintf(s.page(), "<clipPath id=\"clip%d\"><rect x=\"%.3f\" y=\"%.3f\" width=\"%.3f\" height=\"%.3f\"/></clipPath>\n", s.clips, x, y, w, h)
+	fmt.Fprintf(s.page(), "<g clip-path=\"url(#clip%d)\">\n", s.clips)
+}
+
+func (s *SVG) ClipEnd() {
+	s.page().WriteString("</g>\n")
+}
+
+// Image embeds a JPEG, PNG or GIF file, flow and links are ignored.
+func (s *SVG) Image(imageNameStr string, x, y, w, h float64, flow bool, tp string, link int, linkStr string) {
+	data, _, format, w, h, err := readImage(imageNameStr, w, h)
+	if err != nil {
+		if s.err == nil {
+			s.err = err
+		}
+		return
+	}
+	opacity := ""
+	if s.alpha < 1 {
+		opacity = fmt.Sprintf(` opacity="%.3f"`, s.alpha)
+	}
+	fmt.Fprintf(s.page(), "<image x=\"%.3f\" y=\"%.3f\" width=\"%.3f\" height=\"%.3f\" preserveAspectRatio=\"none\" href=\"data:image/%s;base64,%s\"%s/>\n", x, y, w, h, format, base64.StdEncoding.EncodeToString(data), opacity)
+}
+
+// Output writes the SVG document.
+func (s *SVG) Output(w io.Writer) error {
+	if s.footer != nil && len(s.pages) > 0 {
+		s.footer()
+		s.footer = nil
+	}
+	if s.err != nil {
+		return s.err
+	}
+	if err := s.metrics.Error(); err != nil {
+		return err
+	}
+	width, height := s.paperSize.Width, s.paperSize.Height
+	if len(s.pages) > 1 {
+		height = float64(len(s.pages))*(height+pageGap) - pageGap
+	}
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n")
+	fmt.Fprintf(b, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%gmm\" height=\"%gmm\" viewBox=\"0 0 %g %g\">\n", width, height, width, height)
+	if len(s.fonts) > 0 {
+		families := []string{}
+		for family := range s.fonts {
+			families = append(families, family)
+		}
+		sort.Strings(families)
+		b.WriteString("<defs><style>\n")
+		for _, family := range families {
+			fmt.Fprintf(b, "@font-face { font-family: '%s'; src: url(data:font/ttf;base64,%s); }\n", family, base64.StdEncoding.EncodeToString(s.fonts[family]))
+		}
+		b.WriteString("</style></defs>\n")
+	}
+	for i, page := range s.pages {
+		if len(s.pages) == 1 {
+			b.WriteString(page.String())
+			break
+		}
+		// every page gets its sheet of paper
+		fmt.Fprintf(b, "<g transform=\"translate(0 %g)\">\n", float64(i)*(s.paperSize.Height+pageGap))
+		fmt.Fprintf(b, "<rect width=\"%g\" height=\"%g\" fill=\"white\" stroke=\"rgb(200,200,200)\" stroke-width=\"0.2\"/>\n", s.paperSize.Width, s.paperSize.Height)
+		b.WriteString(page.String())
+		b.WriteString("</g>\n")
+	}
+	b.WriteString("</svg>\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,157 @@
+package lineatur
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/opentype"
+)
+
+// coreXHeights are the x-heights of the core fonts relative to the font
+// size.
+var coreXHeights = map[string]float64{
+	"Helvetica": 0.523,
+	"Times":     0.448,
+	"Courier":   0.423,
+}
+
+// trueTypeFont is a TrueType font file with its x-height relative to the
+// font size, the height of its x.
+type trueTypeFont struct {
+	data    []byte
+	xHeight float64
+}
+
+// trueTypeFonts caches the TrueType font files by name.
+var trueTypeFonts sync.Map
+
+// maxFontFileSize is the size of the largest font file read, larger files and
+// devices aren't fonts.
+const maxFontFileSize = 64 << 20
+
+// readTrueTypeFont reads the TrueType font file once, the x-height is 0 if
+// it can't be read or isn't a font.
+func readTrueTypeFont(filename string) trueTypeFont {
+	if f, ok := trueTypeFonts.Load(filename); ok {
+		return f.(trueTypeFont)
+	}
+	ttf := trueTypeFont{}
+	if data, err := readFontFile(filename); err == nil {
+		if f, err := opentype.Parse(data); err == nil {
+			const size = 1000
+			if face, err := opentype.NewFace(f, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingNone}); err == nil {
+				if bounds, _, ok := face.GlyphBounds('x'); ok {
+					ttf = trueTypeFont{data, float64(-bounds.Min.Y) / 64 / size}
+				}
+			}
+		}
+	}
+	trueTypeFonts.Store(filename, ttf)
+	return ttf
+}
+
+// readFontFile reads a regular file of at most maxFontFileSize bytes.
+func readFontFile(filename string) ([]byte, error) {
+	info, err := os.Stat(filename)
+	if err != nil {
+		return nil, err
+	}
+	if !info.Mode().IsRegular() || info.Size() > maxFontFileSize {
+		return nil, fmt.Errorf("%s is no font file", filename)
+	}
+	return os.ReadFile(filename)
+}
+
+// fontFamily returns the family a TrueType font file is added as, the
+// letters and digits of its name with Trace appended, so it can't be taken
+// for a core font.
+func fontFamily(filename string) string {
+	name := strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return -1
+	}, name) + "Trace"
+}
+
+// traceWords returns the words of the text of the lines, a newline in the text
+// is kept as a word of its own.
+func traceWords(text string) []string {
+	words := []string{}
+	for i, paragraph := range strings.Split(text, "\n") {
+		if i > 0 {
+			words = append(words, "\n")
+		}
+		words = append(words, strings.Fields(paragraph)...)
+	}
+	return words
+}
+
+// exemplarColor is the gray of the exemplar text.
+var exemplarColor = Color{150, 150, 150}
+
+// Exemplars are the texts named for the exemplar row, see -exemplar.
+var Exemplars = map[string]string{
+	"alphabet": "a b c d e f g h i j k l m n o p q r s t u v w x y z",
+	"capitals": "A B C D E F G H I J K L M N O P Q R S T U V W X Y Z",
+	"pangram":  "The quick brown fox jumps over the lazy dog.",
+}
+
+// ExemplarNames returns the names of the exemplars, sorted.
+func ExemplarNames() []string {
+	names := []string{}
+	for name := range Exemplars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// drawLineText writes the text into the lines from the first one on, the
+// x-height of the font matches the middle zone and the letters sit on its
+// bottom line. The words wrap at the width of the lines, a newline starts a
+// new line, text not fitting on the page is left out. The text is written in
+// the TrueType font file, or the core font of the configuration without one.
+// Without a readable file nothing is written.
+func drawLineText(pdf Canvas, cfg Config, text, fontFile string, color Color, x, width float64, rows []float64, first int, lineDists func(i int) []float64) {
+	family, xHeight := cfg.Font, coreXHeights[cfg.Font]
+	if fontFile != "" {
+		ttf := readTrueTypeFont(fontFile)
+		if ttf.xHeight <= 0 {
+			return
+		}
+		family, xHeight = fontFamily(fontFile), ttf.xHeight
+		pdf.AddUTF8FontFromBytes(family, "", ttf.data)
+	}
+	pdf.SetTextColor(color.R, color.G, color.B)
+	words := traceWords(text)
+	for i := first; i < len(rows); i++ {
+		if len(words) == 0 {
+			break
+		}
+		y := rows[i]
+		offset, zoneHeight := xHeightZone(cfg.rowHeight(i), lineDists(i), cfg.ZoneGaps)
+		pdf.SetFont(family, "", zoneHeight/xHeight*72/25.4)
+		line := ""
+		for len(words) > 0 && words[0] != "\n" {
+			// a word longer than the line gets a line of its own
+			next := strings.TrimSpace(line + " " + words[0])
+			if line != "" && pdf.GetStringWidth(next) > width {
+				break
+			}
+			line, words = next, words[1:]
+		}
+		if len(words) > 0 && words[0] == "\n" {
+			words = words[1:]
+		}
+		pdf.Text(x, y+offset+zoneHeight, line)
+	}
+	pdf.SetTextColor(0, 0, 0)
+}
@@ -0,0 +1,100 @@
+package lineatur
+
+// cropMarkGap is the distance in mm of the crop marks from the bleed,
+// cropMarkLength their length and cropMarkWidth their line width.
+const (
+	cropMarkGap    = 2.0
+	cropMarkLength = 5.0
+	cropMarkWidth  = 0.25
+)
+
+// trimOffset returns the distance in mm of the trimmed sheet from the edges
+// of the media: the bleed and the space of the crop marks.
+func (cfg Config) trimOffset() float64 {
+	if cfg.CropMarks {
+		return cfg.Bleed + cropMarkGap + cropMarkLength
+	}
+	return cfg.Bleed
+}
+
+// media returns the size of the media the sheet is printed on, larger than
+// the sheet by the bleed and the crop marks.
+func (cfg Config) media() PaperSize {
+	sheet, offset := cfg.sheet(), cfg.trimOffset()
+	return PaperSize{sheet.Width + 2*offset, sheet.Height + 2*offset}
+}
+
+// trimmed draws the pages of the sheet moved onto the larger media, with the
+// crop marks at the corners of the sheet outside the bleed. The media is
+// scaled by the correction of the printer.
+type trimmed struct {
+	Canvas
+	cfg  Config
+	open bool // the page is moved onto the media
+}
+
+// scale scales the media by the correction of the printer.
+func (t *trimmed) scale() {
+	if len(t.cfg.Scale) == 2 {
+		media := t.cfg.media()
+		t.Canvas.TransformScale(100*t.cfg.Scale[0], 100*t.cfg.Scale[1], media.Width/2, media.Height/2)
+	}
+}
+
+func (t *trimmed) AddPage() {
+	t.end()
+	t.Canvas.AddPage()
+	t.Canvas.TransformBegin()
+	t.scale()
+	if t.cfg.CropMarks {
+		drawCropMarks(t.Canvas, t.cfg.sheet(), t.cfg.trimOffset(), t.cfg.Bleed)
+	}
+	offset := t.cfg.trimOffset()
+	t.Canvas.TransformTranslate(offset, offset)
+	t.open = true
+}
+
+// SetFooterFunc moves the footer onto the media too, it is drawn when the
+// page is finished.
+func (t *trimmed) SetFooterFunc(fnc func()) {
+	offset := t.cfg.trimOffset()
+	t.Canvas.SetFooterFunc(func() {
+		t.Canvas.TransformBegin()
+		t.scale()
+		t.Canvas.TransformTranslate(offset, offset)
+		fnc()
+		t.Canvas.TransformEnd()
+	})
+}
+
+// end finishes the move of the current page.
+func (t *trimmed) end() {
+	if t.open {
+		t.Canvas.TransformEnd()
+		t.open = false
+	}
+}
+
+// drawCropMarks draws the marks of the corners of the sheet, offset is the
+// distance of the sheet from the media edges.
+func drawCropMarks(pdf Canvas, sheet PaperSize, offset, bleed float64) {
+	pdf.SetLineWidth(cropMarkWidth)
+	pdf.SetDashPattern([]float64{}, 0)
+	pdf.SetDrawColor(0, 0, 0)
+	pdf.SetAlpha(1, "Normal")
+	start, end := bleed+cropMarkGap, bleed+cropMarkGap+cropMarkLength
+	for _, x := range []float64{offset, offset + sheet.Width} {
+		for _, y := range []float64{offset, offset + sheet.Height} {
+			// the marks point away from the sheet
+			dx, dy := 1.0, 1.0
+			if x == offset {
+				dx = -1
+			}
+			if y == offset {
+				dy = -1
+			}
+			pdf.Line(x+dx*start, y, x+dx*end, y)
+			pdf.Line(x, y+dy*start, x, y+dy*end)
+		}
+	}
+}